@@ -0,0 +1,66 @@
+// Command pwned-bloom builds the Bloom filter used by BREACHED_PASSWORDS_BLOOM from
+// Have I Been Pwned SHA-1 data, either the hash-ordered file or a directory of range files.
+//
+//	go run ./cmd/pwned-bloom -in pwnedpasswords.txt -out pwned.bloom -min-count 10
+package main
+
+import (
+	"JwtSecurityImplementation/pkg/pwned"
+	"flag"
+	"log"
+	"os"
+)
+
+func main() {
+	in := flag.String("in", "", "HIBP SHA-1 file ordered by hash, or directory of range files")
+	out := flag.String("out", "pwned.bloom", "filter file to write")
+	minCount := flag.Int("min-count", 1, "only add passwords seen at least this many times")
+	falsePositiveRate := flag.Float64("fp-rate", 0.001, "false positive rate the filter is sized for")
+	flag.Parse()
+
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// The first pass counts the hashes so the filter can be sized before the second fills it
+	var total uint64
+	err := pwned.ReadHashes(*in, func(hash [20]byte, count int) error {
+		if count >= *minCount {
+			total++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *in, err)
+	}
+
+	filter, err := pwned.NewBloomFilter(total, *falsePositiveRate)
+	if err != nil {
+		log.Fatalf("Failed to create filter: %v", err)
+	}
+
+	err = pwned.ReadHashes(*in, func(hash [20]byte, count int) error {
+		if count >= *minCount {
+			filter.Add(hash)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *in, err)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", *out, err)
+	}
+	size, err := filter.WriteTo(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+
+	log.Printf("Wrote %d hashes seen at least %d times to %s (%d bytes)", total, *minCount, *out, size)
+}
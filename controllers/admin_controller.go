@@ -0,0 +1,350 @@
+package controllers
+
+import (
+	"JwtSecurityImplementation/middleware"
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/responses"
+	"JwtSecurityImplementation/repositories"
+	"JwtSecurityImplementation/services"
+	"JwtSecurityImplementation/utils"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminController struct {
+	lockoutService    *services.LockoutService
+	adminService      *services.AdminService
+	invitationService *services.InvitationService
+}
+
+func NewAdminController(lockoutService *services.LockoutService, adminService *services.AdminService, invitationService *services.InvitationService) *AdminController {
+	return &AdminController{
+		lockoutService:    lockoutService,
+		adminService:      adminService,
+		invitationService: invitationService,
+	}
+}
+
+// ListUsers returns one page of users, optionally searched and filtered
+func (ac *AdminController) ListUsers(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		responses.BadRequestResponse(c, "Invalid page", err)
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		responses.BadRequestResponse(c, "Page size must be between 1 and 100", err)
+		return
+	}
+
+	filter := repositories.UserFilter{
+		Search: strings.TrimSpace(c.Query("search")),
+		Role:   strings.TrimSpace(c.Query("role")),
+		Status: strings.TrimSpace(c.Query("status")),
+		Offset: (page - 1) * pageSize,
+		Limit:  pageSize,
+	}
+	switch filter.Status {
+	case "", models.UserStatusActive, models.UserStatusSuspended, models.UserStatusLocked, models.UserStatusPendingVerification:
+	default:
+		responses.BadRequestResponse(c, "Invalid status filter", nil)
+		return
+	}
+	for param, target := range map[string]**bool{
+		"verified": &filter.Verified,
+		"locked":   &filter.Locked,
+		"mfa":      &filter.MFAEnabled,
+	} {
+		value, ok := c.GetQuery(param)
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			responses.BadRequestResponse(c, "Invalid "+param+" filter", err)
+			return
+		}
+		*target = &parsed
+	}
+
+	users, total, err := ac.adminService.ListUsers(filter)
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to list users", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Users retrieved successfully", gin.H{
+		"users":    users,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// GetUser returns a user and their active sessions
+func (ac *AdminController) GetUser(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		responses.BadRequestResponse(c, "Invalid user ID", err)
+		return
+	}
+
+	details, err := ac.adminService.GetUser(uint(userID))
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "Failed to retrieve user", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "User retrieved successfully", details)
+}
+
+// SuspendUser stops a user from signing in and ends their sessions
+func (ac *AdminController) SuspendUser(c *gin.Context) {
+	actorID, userID, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	var suspendRequest struct {
+		Reason string `json:"reason" validate:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&suspendRequest); err != nil && !errors.Is(err, io.EOF) {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	if err := utils.Validate(&suspendRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	if err := ac.adminService.SuspendUser(userID, actorID, suspendRequest.Reason, clientInfo(c)); err != nil {
+		responses.BadRequestResponse(c, "Failed to suspend user", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "User suspended successfully", nil)
+}
+
+// ReactivateUser lifts the suspension of a user
+func (ac *AdminController) ReactivateUser(c *gin.Context) {
+	actorID, userID, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := ac.adminService.ReactivateUser(userID, actorID, clientInfo(c)); err != nil {
+		responses.BadRequestResponse(c, "Failed to reactivate user", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "User reactivated successfully", nil)
+}
+
+// ForcePasswordReset makes the user choose a new password before signing in with one again
+func (ac *AdminController) ForcePasswordReset(c *gin.Context) {
+	actorID, userID, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := ac.adminService.ForcePasswordReset(userID, actorID, clientInfo(c)); err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "Failed to force password reset", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Password reset required, a reset link has been sent", nil)
+}
+
+// RevokeSessions signs a user out everywhere
+func (ac *AdminController) RevokeSessions(c *gin.Context) {
+	actorID, userID, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := ac.adminService.RevokeSessions(userID, actorID, clientInfo(c)); err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "Failed to revoke sessions", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Sessions revoked successfully", nil)
+}
+
+// SetRoles replaces a user's roles
+func (ac *AdminController) SetRoles(c *gin.Context) {
+	actorID, userID, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	var rolesRequest struct {
+		Roles []string `json:"roles" validate:"required,dive,max=50"`
+	}
+	if err := c.ShouldBindJSON(&rolesRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	if err := utils.Validate(&rolesRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	user, err := ac.adminService.SetRoles(userID, actorID, rolesRequest.Roles, clientInfo(c))
+	if err != nil {
+		responses.BadRequestResponse(c, "Failed to update roles", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Roles updated successfully", user)
+}
+
+// ClearPasskeyCloneWarning lets a user sign in again with a passkey flagged as possibly cloned
+func (ac *AdminController) ClearPasskeyCloneWarning(c *gin.Context) {
+	actorID, userID, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	credentialID, err := strconv.ParseUint(c.Param("passkeyId"), 10, 64)
+	if err != nil {
+		responses.BadRequestResponse(c, "Invalid passkey ID", err)
+		return
+	}
+
+	if err := ac.adminService.ClearPasskeyCloneWarning(userID, uint(credentialID), actorID, clientInfo(c)); err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "Failed to clear clone warning", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Clone warning cleared successfully", nil)
+}
+
+// DeletePasskey removes a passkey from a user account
+func (ac *AdminController) DeletePasskey(c *gin.Context) {
+	actorID, userID, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	credentialID, err := strconv.ParseUint(c.Param("passkeyId"), 10, 64)
+	if err != nil {
+		responses.BadRequestResponse(c, "Invalid passkey ID", err)
+		return
+	}
+
+	if err := ac.adminService.DeletePasskey(userID, uint(credentialID), actorID, clientInfo(c)); err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "Failed to delete passkey", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Passkey deleted successfully", nil)
+}
+
+// UnlockUser lifts the lockout of a user account
+func (ac *AdminController) UnlockUser(c *gin.Context) {
+	actorID, userID, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := ac.lockoutService.Unlock(userID, actorID, clientInfo(c)); err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "Failed to unlock user", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "User unlocked successfully", nil)
+}
+
+// CreateInvitation issues an invitation to register, optionally for one email address
+func (ac *AdminController) CreateInvitation(c *gin.Context) {
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	var createRequest struct {
+		Email  string   `json:"email" validate:"omitempty,email,max=100"`
+		Roles  []string `json:"roles" validate:"dive,max=50"`
+		Tenant string   `json:"tenant" validate:"max=100"`
+		// Falls back to INVITATION_EXPIRY_DAYS when omitted
+		ExpiresInDays int `json:"expires_in_days" validate:"omitempty,min=1"`
+	}
+	if err := c.ShouldBindJSON(&createRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	if err := utils.Validate(&createRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	invitation, code, err := ac.invitationService.Create(actorID, createRequest.Email, createRequest.Roles, createRequest.Tenant, createRequest.ExpiresInDays, clientInfo(c))
+	if err != nil {
+		responses.BadRequestResponse(c, "Failed to create invitation", err)
+		return
+	}
+
+	// The code is only ever shown in this response and the invitation email
+	responses.SuccessResponse(c, http.StatusCreated, "Invitation created", gin.H{
+		"code":       code,
+		"invitation": invitation,
+	})
+}
+
+// ListInvitations returns every invitation, or only pending ones with ?pending=true
+func (ac *AdminController) ListInvitations(c *gin.Context) {
+	pending, err := strconv.ParseBool(c.DefaultQuery("pending", "false"))
+	if err != nil {
+		responses.BadRequestResponse(c, "Invalid pending filter", err)
+		return
+	}
+
+	invitations, err := ac.invitationService.List(pending)
+	if err != nil {
+		responses.InternalServerErrorResponse(c, err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Invitations retrieved successfully", invitations)
+}
+
+// RevokeInvitation stops a pending invitation from being used
+func (ac *AdminController) RevokeInvitation(c *gin.Context) {
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	if err := ac.invitationService.Revoke(c.Param("id"), actorID, clientInfo(c)); err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "Failed to revoke invitation", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Invitation revoked", nil)
+}
+
+// adminTarget returns the acting administrator and the user named in the path,
+// answering the request itself when either is missing
+func adminTarget(c *gin.Context) (uint, uint, bool) {
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return 0, 0, false
+	}
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		responses.BadRequestResponse(c, "Invalid user ID", err)
+		return 0, 0, false
+	}
+
+	return actorID, uint(userID), true
+}
@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"JwtSecurityImplementation/middleware"
 	"JwtSecurityImplementation/models"
 	"JwtSecurityImplementation/pkg/responses"
 	"JwtSecurityImplementation/services"
 	"JwtSecurityImplementation/utils"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -13,59 +16,148 @@ import (
 )
 
 type AuthController struct {
-	authService  *services.AuthService
-	tokenService *services.TokenService
+	authService         *services.AuthService
+	tokenService        *services.TokenService
+	verificationService *services.EmailVerificationService
+	lockoutService      *services.LockoutService
+	mfaService          *services.MFAService
 }
 
-func NewAuthController(authService *services.AuthService, tokenService *services.TokenService) *AuthController {
+func NewAuthController(authService *services.AuthService, tokenService *services.TokenService, verificationService *services.EmailVerificationService, lockoutService *services.LockoutService, mfaService *services.MFAService) *AuthController {
 	return &AuthController{
-		authService:  authService,
-		tokenService: tokenService,
+		authService:         authService,
+		tokenService:        tokenService,
+		verificationService: verificationService,
+		lockoutService:      lockoutService,
+		mfaService:          mfaService,
 	}
 }
 
 func (ac *AuthController) Register(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	// Bind to a request struct, models.User never accepts a password from JSON
+	var registerRequest struct {
+		FirstName       string `json:"firstName" validate:"required"`
+		LastName        string `json:"lastName" validate:"required"`
+		Email           string `json:"email" validate:"required,email"`
+		Password        string `json:"password" validate:"required"`
+		ConfirmPassword string `json:"confirmPassword" validate:"required"`
+		// Required when REGISTRATION_MODE only admits invited users
+		InvitationCode string `json:"invitationCode"`
+	}
+
+	if err := c.ShouldBindJSON(&registerRequest); err != nil {
 		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
 		return
 	}
 
 	// Validate input
-	if err := utils.Validate(&user); err != nil {
+	if err := utils.Validate(&registerRequest); err != nil {
 		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
 		return
 	}
 
 	// Validate password confirmation
-	if user.Password != user.ConfirmPassword {
+	if registerRequest.Password != registerRequest.ConfirmPassword {
 		responses.ErrorResponse(c, http.StatusBadRequest, "Passwords do not match", nil)
 		return
 	}
 
-	// Hash password
-	hashedPassword, err := utils.HashPassword(user.Password)
+	// Create user, the service hashes the password
+	user := models.User{
+		FirstName: registerRequest.FirstName,
+		LastName:  registerRequest.LastName,
+		Email:     registerRequest.Email,
+		Password:  registerRequest.Password,
+	}
+	createdUser, err := ac.authService.Register(&user, strings.TrimSpace(registerRequest.InvitationCode), clientInfo(c))
 	if err != nil {
-		responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to hash password", err)
+		if respondWithPasswordPolicyError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrRegistrationDisabled), errors.Is(err, services.ErrInvitationRequired), errors.Is(err, services.ErrEmailDomainNotAllowed):
+			responses.ErrorResponse(c, http.StatusForbidden, "Registration is not allowed", err)
+			return
+		case errors.Is(err, services.ErrInvitationInvalid):
+			responses.BadRequestResponse(c, "Invalid invitation", err)
+			return
+		}
+		// Without anti-enumeration a taken address is reported, otherwise its owner is told by email
+		if !errors.Is(err, services.ErrEmailTaken) || !services.AntiEnumerationEnabled() {
+			responses.ErrorResponse(c, http.StatusConflict, "Registration failed", err)
+			return
+		}
+
+		go func(email string) {
+			if err := ac.verificationService.NotifyRegistrationAttempt(email); err != nil {
+				log.Printf("Failed to send registration attempt email: %v", err)
+			}
+		}(user.Email)
+		respondWithRegistrationAccepted(c)
 		return
 	}
-	user.Password = hashedPassword
-	user.ConfirmPassword = "" // Clear confirm password after validation
 
-	// Create user
-	createdUser, err := ac.authService.Register(&user)
-	if err != nil {
-		responses.ErrorResponse(c, http.StatusConflict, "Registration failed", err)
+	if services.AntiEnumerationEnabled() {
+		// Send in the background so new and taken addresses take the same time to answer
+		go func(createdUser *models.User) {
+			if err := ac.verificationService.SendVerification(createdUser); err != nil {
+				log.Printf("Failed to send verification email to user %d: %v", createdUser.ID, err)
+			}
+		}(createdUser)
+		respondWithRegistrationAccepted(c)
 		return
 	}
 
+	// The account exists either way, a failed email can be resent later
+	if err := ac.verificationService.SendVerification(createdUser); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", createdUser.ID, err)
+	}
+
 	responses.SuccessResponse(c, http.StatusCreated, "User registered successfully", createdUser)
 }
 
+// respondWithRegistrationAccepted is the single answer to registrations under anti-enumeration
+func respondWithRegistrationAccepted(c *gin.Context) {
+	responses.SuccessResponse(c, http.StatusAccepted, "Registration received, check your email to continue", nil)
+}
+
+// VerifyEmail confirms the user's email address using the token from the verification link
+func (ac *AuthController) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		responses.BadRequestResponse(c, "Verification token is missing", nil)
+		return
+	}
+
+	if err := ac.verificationService.VerifyEmail(token); err != nil {
+		responses.BadRequestResponse(c, "Email verification failed", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Email verified successfully", nil)
+}
+
+// ResendVerification sends a new verification link to the authenticated user
+func (ac *AuthController) ResendVerification(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	if err := ac.verificationService.ResendVerification(userID); err != nil {
+		responses.BadRequestResponse(c, "Failed to send verification email", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Verification email sent", nil)
+}
+
 func (ac *AuthController) Login(c *gin.Context) {
 	var loginRequest struct {
-		Email    string `json:"email" validate:"required,email"`
-		Password string `json:"password" validate:"required"`
+		Email      string `json:"email" validate:"required,email"`
+		Password   string `json:"password" validate:"required"`
+		RememberMe bool   `json:"remember_me"`
 	}
 
 	if err := c.ShouldBindJSON(&loginRequest); err != nil {
@@ -74,23 +166,37 @@ func (ac *AuthController) Login(c *gin.Context) {
 	}
 
 	// Authenticate user
-	user, err := ac.authService.Login(loginRequest.Email, loginRequest.Password)
+	user, err := ac.authService.Login(loginRequest.Email, loginRequest.Password, clientInfo(c))
 	if err != nil {
+		if errors.Is(err, services.ErrEmailNotVerified) || errors.Is(err, services.ErrPasswordExpired) || errors.Is(err, services.ErrAccountSuspended) {
+			responses.ErrorResponse(c, http.StatusForbidden, "Authentication failed", err)
+			return
+		}
+		// Locked accounts answer like a wrong password, see AuthService.Login
 		responses.ErrorResponse(c, http.StatusUnauthorized, "Authentication failed", err)
 		return
 	}
 
+	// Accounts with two-factor authentication get a challenge instead of tokens
+	if user.MFAEnabled {
+		challenge, err := ac.mfaService.IssueChallenge(user, loginRequest.RememberMe, services.FirstFactorPassword)
+		if err != nil {
+			responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to start two-factor authentication", err)
+			return
+		}
+
+		respondWithMFAChallenge(c, challenge)
+		return
+	}
+
 	// Generate tokens
-	tokens, err := ac.tokenService.GenerateTokenPair(user)
+	tokens, err := ac.tokenService.GenerateTokenPair(user, loginRequest.RememberMe, clientInfo(c))
 	if err != nil {
 		responses.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed", err)
 		return
 	}
 
-	responses.SuccessResponse(c, http.StatusOK, "Login successful", gin.H{
-		"access_token":  tokens.AccessToken,
-		"refresh_token": tokens.RefreshToken,
-	})
+	respondWithTokenPair(c, "Login successful", tokens)
 }
 
 func (ac *AuthController) Logout(c *gin.Context) {
@@ -104,6 +210,17 @@ func (ac *AuthController) Logout(c *gin.Context) {
 	// Remove "Bearer " prefix
 	accessToken := strings.TrimPrefix(authHeader, "Bearer ")
 	refreshToken := c.GetHeader("Refresh-Token")
+	if refreshToken == "" {
+		refreshToken, _ = c.Cookie(refreshCookieName())
+	}
+
+	// End the session so every token issued for it stops working
+	if sessionID := c.GetString("session_id"); sessionID != "" {
+		if err := ac.tokenService.RevokeSession(sessionID); err != nil {
+			responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke session", err)
+			return
+		}
+	}
 
 	// Blacklist both tokens
 	err := ac.tokenService.BlacklistToken(accessToken, time.Now().Add(time.Minute*5))
@@ -112,12 +229,15 @@ func (ac *AuthController) Logout(c *gin.Context) {
 		return
 	}
 
-	err = ac.tokenService.BlacklistToken(refreshToken, time.Now().Add(time.Hour*1))
-	if err != nil {
-		responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to blacklist refresh token", err)
-		return
+	if refreshToken != "" {
+		err = ac.tokenService.BlacklistToken(refreshToken, time.Now().Add(time.Hour*1))
+		if err != nil {
+			responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to blacklist refresh token", err)
+			return
+		}
 	}
 
+	clearRefreshCookie(c)
 	responses.SuccessResponse(c, http.StatusOK, "Logout successful", nil)
 }
 
@@ -126,7 +246,10 @@ func (ac *AuthController) RefreshToken(c *gin.Context) {
 		RefreshToken string `json:"refresh_token" validate:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&refreshRequest); err != nil {
+	// Browser clients send the refresh token in a cookie, others in the body
+	if cookie, err := c.Cookie(refreshCookieName()); err == nil && cookie != "" {
+		refreshRequest.RefreshToken = cookie
+	} else if err := c.ShouldBindJSON(&refreshRequest); err != nil {
 		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
 		return
 	}
@@ -149,3 +272,94 @@ func (ac *AuthController) RefreshToken(c *gin.Context) {
 		"access_token": newAccessToken,
 	})
 }
+
+// Me returns the profile of the authenticated user
+func (ac *AuthController) Me(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	user, err := ac.authService.GetUser(userID)
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "User retrieved successfully", user)
+}
+
+// UpdateMe changes the first and last name of the authenticated user
+func (ac *AuthController) UpdateMe(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	var updateRequest struct {
+		FirstName *string `json:"firstName" validate:"omitempty,min=1,max=100"`
+		LastName  *string `json:"lastName" validate:"omitempty,min=1,max=100"`
+	}
+
+	if err := c.ShouldBindJSON(&updateRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&updateRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	user, err := ac.authService.UpdateProfile(userID, updateRequest.FirstName, updateRequest.LastName)
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Profile update failed", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Profile updated successfully", user)
+}
+
+// RequestUnlock emails an unlock link to a locked account.
+// It always succeeds so it cannot be used to discover accounts.
+func (ac *AuthController) RequestUnlock(c *gin.Context) {
+	var unlockRequest struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&unlockRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&unlockRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	go func(email string) {
+		if err := ac.lockoutService.RequestUnlock(email); err != nil {
+			log.Printf("Failed to send unlock email: %v", err)
+		}
+	}(unlockRequest.Email)
+
+	responses.SuccessResponse(c, http.StatusOK, "If the account is locked, an unlock link has been sent", nil)
+}
+
+// Unlock lifts a lockout using the token from an unlock email
+func (ac *AuthController) Unlock(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		responses.BadRequestResponse(c, "Unlock token is missing", nil)
+		return
+	}
+
+	if err := ac.lockoutService.UnlockWithToken(token, clientInfo(c)); err != nil {
+		responses.BadRequestResponse(c, "Account unlock failed", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Account unlocked successfully", nil)
+}
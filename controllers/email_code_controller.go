@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"JwtSecurityImplementation/middleware"
+	"JwtSecurityImplementation/pkg/responses"
+	"JwtSecurityImplementation/services"
+	"JwtSecurityImplementation/utils"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailCodeController struct {
+	emailCodeService *services.EmailCodeService
+	mfaService       *services.MFAService
+	tokenService     *services.TokenService
+}
+
+func NewEmailCodeController(emailCodeService *services.EmailCodeService, mfaService *services.MFAService, tokenService *services.TokenService) *EmailCodeController {
+	return &EmailCodeController{
+		emailCodeService: emailCodeService,
+		mfaService:       mfaService,
+		tokenService:     tokenService,
+	}
+}
+
+// RequestLoginCode emails a login code; the response is the same for unknown addresses
+func (ec *EmailCodeController) RequestLoginCode(c *gin.Context) {
+	var codeRequest struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&codeRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&codeRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	// Send in the background so known and unknown addresses take the same time to answer
+	go func(email string) {
+		if err := ec.emailCodeService.RequestLoginCode(email); err != nil {
+			log.Printf("Failed to send login code email: %v", err)
+		}
+	}(codeRequest.Email)
+
+	responses.SuccessResponse(c, http.StatusOK, "If an account exists for this email, a code has been sent", nil)
+}
+
+// Login exchanges an emailed code for a token pair, or an MFA challenge if the account has MFA enabled
+func (ec *EmailCodeController) Login(c *gin.Context) {
+	var loginRequest struct {
+		Email      string `json:"email" validate:"required,email"`
+		Code       string `json:"code" validate:"required"`
+		RememberMe bool   `json:"remember_me"`
+	}
+
+	if err := c.ShouldBindJSON(&loginRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&loginRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	user, err := ec.emailCodeService.Login(loginRequest.Email, loginRequest.Code, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrPasswordResetRequired) {
+			responses.ErrorResponse(c, http.StatusForbidden, "Authentication failed", err)
+			return
+		}
+		responses.ErrorResponse(c, http.StatusUnauthorized, "Authentication failed", err)
+		return
+	}
+
+	if user.MFAEnabled {
+		challenge, err := ec.mfaService.IssueChallenge(user, loginRequest.RememberMe, services.FirstFactorEmailCode)
+		if err != nil {
+			responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to start two-factor authentication", err)
+			return
+		}
+		respondWithMFAChallenge(c, challenge)
+		return
+	}
+
+	tokens, err := ec.tokenService.GenerateTokenPair(user, loginRequest.RememberMe, clientInfo(c))
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed", err)
+		return
+	}
+
+	respondWithTokenPair(c, "Login successful", tokens)
+}
+
+// SendMFACode emails a code that completes a pending MFA challenge
+func (ec *EmailCodeController) SendMFACode(c *gin.Context) {
+	var sendRequest struct {
+		MFAToken string `json:"mfa_token" validate:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&sendRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&sendRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	if err := ec.mfaService.SendChallengeEmailCode(sendRequest.MFAToken); err != nil {
+		respondWithEmailCodeError(c, err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Verification code sent", nil)
+}
+
+// SendStepUpCode emails a step-up code to the authenticated user
+func (ec *EmailCodeController) SendStepUpCode(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	if err := ec.emailCodeService.SendStepUpCode(userID); err != nil {
+		respondWithEmailCodeError(c, err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Verification code sent", nil)
+}
+
+// VerifyStepUp exchanges an emailed code, or an authenticator code, for a step-up token
+// that unlocks sensitive actions in the current session for a few minutes
+func (ec *EmailCodeController) VerifyStepUp(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	var verifyRequest struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&verifyRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&verifyRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	err := ec.emailCodeService.Verify(userID, services.EmailCodeStepUp, verifyRequest.Code)
+	if err != nil && ec.mfaService.VerifyCode(userID, verifyRequest.Code) == nil {
+		err = nil
+	}
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusUnauthorized, "Step-up verification failed", err)
+		return
+	}
+
+	stepUpToken, expiresAt, err := ec.tokenService.IssueStepUpToken(userID, c.GetString("session_id"))
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Step-up verification successful", gin.H{
+		"step_up_token": stepUpToken,
+		"expires_at":    expiresAt,
+	})
+}
+
+// respondWithEmailCodeError maps throttled resends to 429
+func respondWithEmailCodeError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrEmailCodeThrottled) {
+		responses.ErrorResponse(c, http.StatusTooManyRequests, "Failed to send verification code", err)
+		return
+	}
+	responses.BadRequestResponse(c, "Failed to send verification code", err)
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/pkg/responses"
+	"JwtSecurityImplementation/services"
+	"JwtSecurityImplementation/utils"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	magicLinkCookieName = "magic_link_device"
+	magicLinkCookiePath = "/auth/magic-link"
+)
+
+// magicLinkPage asks for a click before the link is consumed, so mail scanners
+// that prefetch links cannot use them up
+var magicLinkPage = template.Must(template.New("magic-link").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+<form method="POST" action="/auth/magic-link/consume">
+<input type="hidden" name="token" value="{{.}}">
+<label><input type="checkbox" name="remember_me" value="true"> Keep me signed in</label>
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+type MagicLinkController struct {
+	magicLinkService *services.MagicLinkService
+	tokenService     *services.TokenService
+	mfaService       *services.MFAService
+}
+
+func NewMagicLinkController(magicLinkService *services.MagicLinkService, tokenService *services.TokenService, mfaService *services.MFAService) *MagicLinkController {
+	return &MagicLinkController{
+		magicLinkService: magicLinkService,
+		tokenService:     tokenService,
+		mfaService:       mfaService,
+	}
+}
+
+// Request emails a sign-in link and binds it to this browser with a cookie
+func (mc *MagicLinkController) Request(c *gin.Context) {
+	var linkRequest struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&linkRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&linkRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	deviceSecret, err := services.NewDeviceSecret()
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to send sign-in link", err)
+		return
+	}
+
+	// The cookie is set for unknown addresses too, so responses look the same
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(magicLinkCookieName, deviceSecret, int(services.MagicLinkTTL().Seconds()), magicLinkCookiePath,
+		config.GetEnv("COOKIE_DOMAIN", ""), config.GetEnvBool("COOKIE_SECURE", true), true)
+
+	// Send in the background so known and unknown addresses take the same time to answer
+	go func(email string) {
+		if err := mc.magicLinkService.SendLink(email, deviceSecret); err != nil {
+			log.Printf("Failed to send magic link email: %v", err)
+		}
+	}(linkRequest.Email)
+
+	responses.SuccessResponse(c, http.StatusOK, "If an account exists for this email, a sign-in link has been sent", nil)
+}
+
+// Confirm shows the page the emailed link points to; it does not consume the link
+func (mc *MagicLinkController) Confirm(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		responses.BadRequestResponse(c, "Sign-in token is missing", nil)
+		return
+	}
+
+	// Keep the token out of caches and the Referer header of anything the page loads
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := magicLinkPage.Execute(c.Writer, token); err != nil {
+		log.Printf("Failed to render magic link page: %v", err)
+	}
+}
+
+// Consume exchanges a magic link for a token pair, or an MFA challenge if the account has MFA enabled
+func (mc *MagicLinkController) Consume(c *gin.Context) {
+	var consumeRequest struct {
+		Token      string `form:"token" json:"token" validate:"required"`
+		RememberMe bool   `form:"remember_me" json:"remember_me"`
+	}
+
+	if err := c.ShouldBind(&consumeRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&consumeRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	deviceSecret, _ := c.Cookie(magicLinkCookieName)
+	user, err := mc.magicLinkService.ConsumeLink(consumeRequest.Token, deviceSecret)
+	if err != nil {
+		if errors.Is(err, services.ErrPasswordResetRequired) {
+			responses.ErrorResponse(c, http.StatusForbidden, "Authentication failed", err)
+			return
+		}
+		responses.ErrorResponse(c, http.StatusUnauthorized, "Authentication failed", err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(magicLinkCookieName, "", -1, magicLinkCookiePath,
+		config.GetEnv("COOKIE_DOMAIN", ""), config.GetEnvBool("COOKIE_SECURE", true), true)
+
+	if user.MFAEnabled {
+		challenge, err := mc.mfaService.IssueChallenge(user, consumeRequest.RememberMe, services.FirstFactorMagicLink)
+		if err != nil {
+			responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to start two-factor authentication", err)
+			return
+		}
+		respondWithMFAChallenge(c, challenge)
+		return
+	}
+
+	tokens, err := mc.tokenService.GenerateTokenPair(user, consumeRequest.RememberMe, clientInfo(c))
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed", err)
+		return
+	}
+
+	// Browsers coming from the confirmation page go back to the app, which refreshes with the cookie
+	if c.ContentType() != gin.MIMEJSON {
+		respondWithBrowserLogin(c, config.GetEnv("MAGIC_LINK_REDIRECT_URL", ""), tokens)
+		return
+	}
+
+	respondWithTokenPair(c, "Login successful", tokens)
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"JwtSecurityImplementation/middleware"
+	"JwtSecurityImplementation/pkg/responses"
+	"JwtSecurityImplementation/services"
+	"JwtSecurityImplementation/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAController struct {
+	mfaService   *services.MFAService
+	tokenService *services.TokenService
+}
+
+func NewMFAController(mfaService *services.MFAService, tokenService *services.TokenService) *MFAController {
+	return &MFAController{
+		mfaService:   mfaService,
+		tokenService: tokenService,
+	}
+}
+
+// Verify completes a login by exchanging the MFA challenge and a code for a token pair
+func (mc *MFAController) Verify(c *gin.Context) {
+	var verifyRequest struct {
+		MFAToken string `json:"mfa_token" validate:"required"`
+		Code     string `json:"code" validate:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&verifyRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&verifyRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	challenge, err := mc.mfaService.VerifyChallenge(verifyRequest.MFAToken, verifyRequest.Code, clientInfo(c))
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusUnauthorized, "Two-factor authentication failed", err)
+		return
+	}
+
+	tokens, err := mc.tokenService.GenerateTokenPair(challenge.User, challenge.RememberMe, clientInfo(c))
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed", err)
+		return
+	}
+
+	respondWithTokenPair(c, "Login successful", tokens)
+}
+
+// Status reports the two-factor authentication state of the authenticated user
+func (mc *MFAController) Status(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	status, err := mc.mfaService.Status(userID)
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "Failed to load two-factor authentication status", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Two-factor authentication status", status)
+}
+
+// EnrollTOTP starts authenticator app enrollment for the authenticated user
+func (mc *MFAController) EnrollTOTP(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	enrollment, err := mc.mfaService.BeginTOTPEnrollment(userID)
+	if err != nil {
+		responses.BadRequestResponse(c, "Failed to start enrollment", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Scan the QR code and confirm with a code from your authenticator app", enrollment)
+}
+
+// ConfirmTOTP enables two-factor authentication and returns the recovery codes
+func (mc *MFAController) ConfirmTOTP(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	var confirmRequest struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&confirmRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&confirmRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	codes, err := mc.mfaService.ConfirmTOTPEnrollment(userID, confirmRequest.Code)
+	if err != nil {
+		responses.BadRequestResponse(c, "Failed to enable two-factor authentication", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Two-factor authentication enabled, store the recovery codes somewhere safe", gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+// Disable turns off two-factor authentication for the authenticated user
+func (mc *MFAController) Disable(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	var disableRequest struct {
+		Password string `json:"password" validate:"required"`
+		Code     string `json:"code" validate:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&disableRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&disableRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	if err := mc.mfaService.DisableMFA(userID, disableRequest.Password, disableRequest.Code); err != nil {
+		responses.BadRequestResponse(c, "Failed to disable two-factor authentication", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the authenticated user
+func (mc *MFAController) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	var regenerateRequest struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&regenerateRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&regenerateRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	codes, err := mc.mfaService.RegenerateRecoveryCodes(userID, regenerateRequest.Code)
+	if err != nil {
+		responses.BadRequestResponse(c, "Failed to regenerate recovery codes", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Recovery codes regenerated", gin.H{
+		"recovery_codes": codes,
+	})
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/middleware"
+	"JwtSecurityImplementation/pkg/responses"
+	"JwtSecurityImplementation/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	oidcFlowCookieName = "oidc_flow"
+	oidcFlowCookiePath = "/auth/oidc"
+)
+
+type OIDCController struct {
+	oidcService  *services.OIDCService
+	tokenService *services.TokenService
+	mfaService   *services.MFAService
+}
+
+func NewOIDCController(oidcService *services.OIDCService, tokenService *services.TokenService, mfaService *services.MFAService) *OIDCController {
+	return &OIDCController{
+		oidcService:  oidcService,
+		tokenService: tokenService,
+		mfaService:   mfaService,
+	}
+}
+
+// Providers lists the identity providers users can sign in with
+func (oc *OIDCController) Providers(c *gin.Context) {
+	responses.SuccessResponse(c, http.StatusOK, "Identity providers retrieved successfully", oc.oidcService.Providers())
+}
+
+// Login redirects the browser to the identity provider
+func (oc *OIDCController) Login(c *gin.Context) {
+	rememberMe, _ := strconv.ParseBool(c.Query("remember_me"))
+
+	authURL, flow, err := oc.oidcService.BeginLogin(c.Request.Context(), c.Param("provider"), rememberMe)
+	if err != nil {
+		responses.BadRequestResponse(c, "Failed to start sign-in", err)
+		return
+	}
+
+	// Lax, because the provider sends the browser back with a cross-site redirect
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookieName, flow, int((10 * time.Minute).Seconds()), oidcFlowCookiePath,
+		config.GetEnv("COOKIE_DOMAIN", ""), config.GetEnvBool("COOKIE_SECURE", true), true)
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes the sign-in when the identity provider redirects back
+func (oc *OIDCController) Callback(c *gin.Context) {
+	if errorCode := c.Query("error"); errorCode != "" {
+		responses.ErrorResponse(c, http.StatusUnauthorized, "Authentication failed", errors.New(errorCode+": "+c.Query("error_description")))
+		return
+	}
+
+	flow, _ := c.Cookie(oidcFlowCookieName)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookieName, "", -1, oidcFlowCookiePath,
+		config.GetEnv("COOKIE_DOMAIN", ""), config.GetEnvBool("COOKIE_SECURE", true), true)
+
+	login, err := oc.oidcService.FinishLogin(c.Request.Context(), c.Param("provider"), flow, c.Query("state"), c.Query("code"))
+	if err != nil {
+		if errors.Is(err, services.ErrPasswordResetRequired) {
+			responses.ErrorResponse(c, http.StatusForbidden, "Authentication failed", err)
+			return
+		}
+		responses.ErrorResponse(c, http.StatusUnauthorized, "Authentication failed", err)
+		return
+	}
+
+	if login.User.MFAEnabled {
+		challenge, err := oc.mfaService.IssueChallenge(login.User, login.RememberMe, services.FirstFactorOIDC)
+		if err != nil {
+			responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to start two-factor authentication", err)
+			return
+		}
+		respondWithMFAChallenge(c, challenge)
+		return
+	}
+
+	tokens, err := oc.tokenService.GenerateTokenPair(login.User, login.RememberMe, clientInfo(c))
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed", err)
+		return
+	}
+
+	respondWithBrowserLogin(c, config.GetEnv("OIDC_SUCCESS_REDIRECT_URL", ""), tokens)
+}
+
+// ListIdentities returns the identity provider accounts linked to the authenticated user
+func (oc *OIDCController) ListIdentities(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	identities, err := oc.oidcService.ListIdentities(userID)
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to list linked identities", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Linked identities retrieved successfully", identities)
+}
+
+// UnlinkIdentity removes an identity provider account from the authenticated user
+func (oc *OIDCController) UnlinkIdentity(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	identityID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		responses.BadRequestResponse(c, "Invalid identity ID", err)
+		return
+	}
+
+	if err := oc.oidcService.UnlinkIdentity(userID, uint(identityID)); err != nil {
+		responses.BadRequestResponse(c, "Failed to unlink identity", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Identity unlinked successfully", nil)
+}
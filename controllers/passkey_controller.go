@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"JwtSecurityImplementation/middleware"
+	"JwtSecurityImplementation/pkg/responses"
+	"JwtSecurityImplementation/services"
+	"JwtSecurityImplementation/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasskeyController struct {
+	passkeyService *services.PasskeyService
+	tokenService   *services.TokenService
+}
+
+func NewPasskeyController(passkeyService *services.PasskeyService, tokenService *services.TokenService) *PasskeyController {
+	return &PasskeyController{
+		passkeyService: passkeyService,
+		tokenService:   tokenService,
+	}
+}
+
+// BeginLogin starts a passkey login, either passwordless or as the second factor of a password login
+func (pc *PasskeyController) BeginLogin(c *gin.Context) {
+	var beginRequest struct {
+		MFAToken   string `json:"mfa_token"`
+		RememberMe bool   `json:"remember_me"`
+	}
+
+	if err := c.ShouldBindJSON(&beginRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&beginRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	options, session, err := pc.passkeyService.BeginLogin(beginRequest.MFAToken, beginRequest.RememberMe)
+	if err != nil {
+		responses.BadRequestResponse(c, "Failed to start passkey login", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Passkey login started", gin.H{
+		"options": options,
+		"session": session,
+	})
+}
+
+// FinishLogin verifies the assertion from the authenticator and issues a token pair
+func (pc *PasskeyController) FinishLogin(c *gin.Context) {
+	var finishRequest struct {
+		Session    string          `json:"session" validate:"required"`
+		Credential json.RawMessage `json:"credential" validate:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&finishRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&finishRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	login, err := pc.passkeyService.FinishLogin(finishRequest.Session, finishRequest.Credential)
+	if err != nil {
+		if errors.Is(err, services.ErrEmailNotVerified) || errors.Is(err, services.ErrPasswordResetRequired) {
+			responses.ErrorResponse(c, http.StatusForbidden, "Authentication failed", err)
+			return
+		}
+		responses.ErrorResponse(c, http.StatusUnauthorized, "Authentication failed", err)
+		return
+	}
+
+	tokens, err := pc.tokenService.GenerateTokenPair(login.User, login.RememberMe, clientInfo(c))
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed", err)
+		return
+	}
+
+	respondWithTokenPair(c, "Login successful", tokens)
+}
+
+// BeginRegistration starts registering a new passkey for the authenticated user
+func (pc *PasskeyController) BeginRegistration(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	options, session, err := pc.passkeyService.BeginRegistration(userID)
+	if err != nil {
+		responses.BadRequestResponse(c, "Failed to start passkey registration", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Passkey registration started", gin.H{
+		"options": options,
+		"session": session,
+	})
+}
+
+// FinishRegistration verifies the attestation and stores the new passkey
+func (pc *PasskeyController) FinishRegistration(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	var finishRequest struct {
+		Session    string          `json:"session" validate:"required"`
+		Name       string          `json:"name" validate:"max=100"`
+		Credential json.RawMessage `json:"credential" validate:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&finishRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&finishRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	credential, err := pc.passkeyService.FinishRegistration(userID, finishRequest.Session, finishRequest.Name, finishRequest.Credential)
+	if err != nil {
+		responses.BadRequestResponse(c, "Passkey registration failed", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusCreated, "Passkey registered successfully", credential)
+}
+
+// ListPasskeys returns the passkeys of the authenticated user
+func (pc *PasskeyController) ListPasskeys(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	credentials, err := pc.passkeyService.ListPasskeys(userID)
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusInternalServerError, "Failed to list passkeys", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Passkeys retrieved successfully", credentials)
+}
+
+// RenamePasskey changes the name of one of the authenticated user's passkeys
+func (pc *PasskeyController) RenamePasskey(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	credentialID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		responses.BadRequestResponse(c, "Invalid passkey ID", err)
+		return
+	}
+
+	var renameRequest struct {
+		Name string `json:"name" validate:"required,max=100"`
+	}
+
+	if err := c.ShouldBindJSON(&renameRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&renameRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	if err := pc.passkeyService.RenamePasskey(userID, uint(credentialID), renameRequest.Name); err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "Failed to rename passkey", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Passkey renamed successfully", nil)
+}
+
+// DeletePasskey removes one of the authenticated user's passkeys
+func (pc *PasskeyController) DeletePasskey(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	credentialID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		responses.BadRequestResponse(c, "Invalid passkey ID", err)
+		return
+	}
+
+	if err := pc.passkeyService.DeletePasskey(userID, uint(credentialID)); err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "Failed to delete passkey", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Passkey deleted successfully", nil)
+}
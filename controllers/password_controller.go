@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"JwtSecurityImplementation/middleware"
+	"JwtSecurityImplementation/pkg/responses"
+	"JwtSecurityImplementation/services"
+	"JwtSecurityImplementation/utils"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordController struct {
+	passwordService *services.PasswordService
+}
+
+func NewPasswordController(passwordService *services.PasswordService) *PasswordController {
+	return &PasswordController{passwordService: passwordService}
+}
+
+func (pc *PasswordController) ForgotPassword(c *gin.Context) {
+	var forgotRequest struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&forgotRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&forgotRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	// Send in the background so known and unknown addresses take the same time to answer
+	go func(email string) {
+		if err := pc.passwordService.ForgotPassword(email); err != nil {
+			log.Printf("Failed to send password reset email: %v", err)
+		}
+	}(forgotRequest.Email)
+
+	responses.SuccessResponse(c, http.StatusOK, "If an account exists for this email, a reset link has been sent", nil)
+}
+
+func (pc *PasswordController) ResetPassword(c *gin.Context) {
+	var resetRequest struct {
+		Token           string `json:"token" validate:"required"`
+		Password        string `json:"password" validate:"required"`
+		ConfirmPassword string `json:"confirmPassword" validate:"required,eqfield=Password"`
+	}
+
+	if err := c.ShouldBindJSON(&resetRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&resetRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	if err := pc.passwordService.ResetPassword(resetRequest.Token, resetRequest.Password); err != nil {
+		if respondWithPasswordPolicyError(c, err) {
+			return
+		}
+		responses.ErrorResponse(c, http.StatusBadRequest, "Password reset failed", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Password reset successfully", nil)
+}
+
+func (pc *PasswordController) ChangePassword(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	var changeRequest struct {
+		CurrentPassword string `json:"currentPassword" validate:"required"`
+		Password        string `json:"password" validate:"required"`
+		ConfirmPassword string `json:"confirmPassword" validate:"required,eqfield=Password"`
+	}
+
+	if err := c.ShouldBindJSON(&changeRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&changeRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	err := pc.passwordService.ChangePassword(userID, c.GetString("session_id"), changeRequest.CurrentPassword, changeRequest.Password)
+	if err != nil {
+		if respondWithPasswordPolicyError(c, err) {
+			return
+		}
+		responses.ErrorResponse(c, http.StatusBadRequest, "Password change failed", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Password changed successfully", nil)
+}
+
+// respondWithPasswordPolicyError answers with every broken password rule if err is a policy error
+func respondWithPasswordPolicyError(c *gin.Context, err error) bool {
+	var policyErr *services.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	responses.ValidationErrorResponse(c, policyErr.Violations)
+	return true
+}
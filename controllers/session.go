@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/responses"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const refreshCookiePath = "/auth"
+
+// setRefreshCookie stores the refresh token in an HTTP-only cookie.
+// Remember-me sessions get a persistent cookie, all others a browser-session cookie.
+func setRefreshCookie(c *gin.Context, tokens *models.TokenDetails) {
+	maxAge := 0
+	if tokens.RememberMe {
+		maxAge = int(time.Until(tokens.RefreshTokenExpiry).Seconds())
+	}
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(refreshCookieName(), tokens.RefreshToken, maxAge, refreshCookiePath,
+		config.GetEnv("COOKIE_DOMAIN", ""), config.GetEnvBool("COOKIE_SECURE", true), true)
+}
+
+// clearRefreshCookie removes the refresh token cookie from the browser
+func clearRefreshCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(refreshCookieName(), "", -1, refreshCookiePath,
+		config.GetEnv("COOKIE_DOMAIN", ""), config.GetEnvBool("COOKIE_SECURE", true), true)
+}
+
+func refreshCookieName() string {
+	return config.GetEnv("REFRESH_COOKIE_NAME", "refresh_token")
+}
+
+// clientInfo extracts the client details recorded against a session
+func clientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// respondWithTokenPair sends a freshly issued token pair and sets the refresh cookie
+func respondWithTokenPair(c *gin.Context, message string, tokens *models.TokenDetails) {
+	setRefreshCookie(c, tokens)
+
+	responses.SuccessResponse(c, http.StatusOK, message, gin.H{
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"remember_me":   tokens.RememberMe,
+	})
+}
+
+// respondWithMFAChallenge asks the client to complete the login with a second factor
+func respondWithMFAChallenge(c *gin.Context, challenge string) {
+	responses.SuccessResponse(c, http.StatusOK, "Two-factor authentication required", gin.H{
+		"mfa_required": true,
+		"mfa_token":    challenge,
+	})
+}
+
+// respondWithBrowserLogin finishes a login that happened through browser navigation. With a
+// redirect URL the browser goes back to the app, which uses the refresh cookie to get an access token.
+func respondWithBrowserLogin(c *gin.Context, redirectURL string, tokens *models.TokenDetails) {
+	if redirectURL == "" {
+		respondWithTokenPair(c, "Login successful", tokens)
+		return
+	}
+
+	setRefreshCookie(c, tokens)
+	c.Redirect(http.StatusSeeOther, redirectURL)
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"JwtSecurityImplementation/middleware"
+	"JwtSecurityImplementation/pkg/responses"
+	"JwtSecurityImplementation/services"
+	"JwtSecurityImplementation/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TokenController struct {
+	tokenService *services.TokenService
+}
+
+func NewTokenController(tokenService *services.TokenService) *TokenController {
+	return &TokenController{tokenService: tokenService}
+}
+
+// Inventory lists the user's active sessions and personal access tokens
+func (tc *TokenController) Inventory(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	inventory, err := tc.tokenService.GetTokenInventory(userID)
+	if err != nil {
+		responses.InternalServerErrorResponse(c, err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Token inventory retrieved", inventory)
+}
+
+func (tc *TokenController) CreatePersonalAccessToken(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	var createRequest struct {
+		Name          string   `json:"name" validate:"required,max=100"`
+		Scopes        []string `json:"scopes" validate:"required,min=1"`
+		ExpiresInDays int      `json:"expires_in_days" validate:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&createRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	if err := utils.Validate(&createRequest); err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
+		return
+	}
+
+	pat, token, err := tc.tokenService.CreatePersonalAccessToken(userID, createRequest.Name, createRequest.Scopes, createRequest.ExpiresInDays)
+	if err != nil {
+		responses.ErrorResponse(c, http.StatusBadRequest, "Failed to create personal access token", err)
+		return
+	}
+
+	// The token is only ever shown in this response
+	responses.SuccessResponse(c, http.StatusCreated, "Personal access token created", gin.H{
+		"token":                 token,
+		"personal_access_token": pat,
+	})
+}
+
+func (tc *TokenController) ListPersonalAccessTokens(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	pats, err := tc.tokenService.ListPersonalAccessTokens(userID)
+	if err != nil {
+		responses.InternalServerErrorResponse(c, err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Personal access tokens retrieved", pats)
+}
+
+func (tc *TokenController) RevokePersonalAccessToken(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		responses.UnauthorizedResponse(c, "Unauthorized")
+		return
+	}
+
+	if err := tc.tokenService.RevokePersonalAccessToken(userID, c.Param("id")); err != nil {
+		responses.ErrorResponse(c, http.StatusNotFound, "Failed to revoke personal access token", err)
+		return
+	}
+
+	responses.SuccessResponse(c, http.StatusOK, "Personal access token revoked", nil)
+}
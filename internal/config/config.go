@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
 	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -44,10 +45,16 @@ func InitDatabase() (*gorm.DB, error) {
 	}
 
 	// Auto Migrate
-	err = db.AutoMigrate(&models.User{}, &models.BlacklistedToken{})
+	err = db.AutoMigrate(&models.User{}, &models.BlacklistedToken{}, &models.Session{}, &models.PersonalAccessToken{}, &models.Lease{}, &models.OneTimeToken{}, &models.PasswordHistory{}, &models.AuditEvent{}, &models.RecoveryCode{}, &models.WebAuthnCredential{}, &models.EmailCode{}, &models.ExternalIdentity{}, &models.Invitation{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
+	if err := repositories.MigrateLegacyBlacklist(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate token blacklist: %w", err)
+	}
+	if err := repositories.MigrateUserStatus(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate user status: %w", err)
+	}
 
 	return db, nil
 }
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// GetEnv returns the value of an environment variable or the fallback if it is unset
+func GetEnv(key, fallback string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvInt returns an integer environment variable or the fallback if it is unset or invalid
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvBool returns a boolean environment variable or the fallback if it is unset or invalid
+func GetEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
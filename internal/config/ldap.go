@@ -0,0 +1,30 @@
+package config
+
+import (
+	"time"
+
+	"JwtSecurityImplementation/pkg/ldapauth"
+)
+
+// InitLDAP creates the directory client used by the ldap authenticator, or nil when LDAP_URL is not set
+func InitLDAP() *ldapauth.Client {
+	url := GetEnv("LDAP_URL", "")
+	if url == "" {
+		return nil
+	}
+
+	return ldapauth.NewClient(ldapauth.Config{
+		URL:                url,
+		StartTLS:           GetEnvBool("LDAP_START_TLS", false),
+		InsecureSkipVerify: GetEnvBool("LDAP_INSECURE_SKIP_VERIFY", false),
+		BindDN:             GetEnv("LDAP_BIND_DN", ""),
+		BindPassword:       GetEnv("LDAP_BIND_PASSWORD", ""),
+		BaseDN:             GetEnv("LDAP_BASE_DN", ""),
+		UserFilter:         GetEnv("LDAP_USER_FILTER", "(&(objectClass=user)(|(mail=%s)(userPrincipalName=%s)))"),
+		EmailAttribute:     GetEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		FirstNameAttribute: GetEnv("LDAP_FIRST_NAME_ATTRIBUTE", "givenName"),
+		LastNameAttribute:  GetEnv("LDAP_LAST_NAME_ATTRIBUTE", "sn"),
+		GroupAttribute:     GetEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		Timeout:            time.Second * time.Duration(GetEnvInt("LDAP_TIMEOUT", 10)),
+	})
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+
+	"JwtSecurityImplementation/pkg/mailer"
+)
+
+// InitMailer creates the mailer selected by MAILER (log, file or smtp)
+func InitMailer() (mailer.Mailer, error) {
+	from := GetEnv("MAIL_FROM", "no-reply@localhost")
+
+	switch backend := GetEnv("MAILER", "log"); backend {
+	case "log":
+		return mailer.NewLogMailer(from), nil
+	case "file":
+		return mailer.NewFileMailer(GetEnv("MAIL_FILE", "mail.log"), from)
+	case "smtp":
+		return mailer.NewSMTPMailer(
+			GetEnv("SMTP_HOST", "localhost"),
+			GetEnv("SMTP_PORT", "587"),
+			GetEnv("SMTP_USERNAME", ""),
+			GetEnv("SMTP_PASSWORD", ""),
+			from,
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", backend)
+	}
+}
@@ -0,0 +1,25 @@
+package config
+
+import "JwtSecurityImplementation/pkg/pwned"
+
+// InitBreachedPasswords opens the offline breach corpus new passwords are checked against,
+// or returns nil when neither BREACHED_PASSWORDS_BLOOM nor BREACHED_PASSWORDS_FILE is set
+func InitBreachedPasswords() (pwned.Checker, error) {
+	if path := GetEnv("BREACHED_PASSWORDS_BLOOM", ""); path != "" {
+		filter, err := pwned.LoadBloomFilter(path)
+		if err != nil {
+			return nil, err
+		}
+		return filter, nil
+	}
+
+	if path := GetEnv("BREACHED_PASSWORDS_FILE", ""); path != "" {
+		checker, err := pwned.NewFileChecker(path, GetEnvInt("BREACHED_PASSWORDS_THRESHOLD", 1))
+		if err != nil {
+			return nil, err
+		}
+		return checker, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"fmt"
+
+	"JwtSecurityImplementation/pkg/ratelimit"
+)
+
+// InitRateLimitStore creates the rate limit store selected by RATE_LIMIT_STORE (memory or redis).
+// Use redis when several replicas must share the same limits.
+func InitRateLimitStore() (ratelimit.Store, error) {
+	switch backend := GetEnv("RATE_LIMIT_STORE", "memory"); backend {
+	case "memory":
+		return ratelimit.NewMemoryStore(), nil
+	case "redis":
+		client, err := InitRedis()
+		if err != nil {
+			return nil, err
+		}
+		return ratelimit.NewRedisStore(client, GetEnv("REDIS_KEY_PREFIX", "jwt:")+"ratelimit:"), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", backend)
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisOnce   sync.Once
+	redisClient *redis.Client
+	redisErr    error
+)
+
+// InitRedis connects to the Redis-protocol server configured by REDIS_URL.
+// The client is shared, so every caller gets the same connection pool.
+func InitRedis() (*redis.Client, error) {
+	redisOnce.Do(func() {
+		options, err := redis.ParseURL(GetEnv("REDIS_URL", "redis://localhost:6379/0"))
+		if err != nil {
+			redisErr = fmt.Errorf("invalid REDIS_URL: %w", err)
+			return
+		}
+
+		client := redis.NewClient(options)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			redisErr = fmt.Errorf("failed to connect to redis: %w", err)
+			return
+		}
+
+		redisClient = client
+	})
+
+	return redisClient, redisErr
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+
+	"JwtSecurityImplementation/repositories"
+	"gorm.io/gorm"
+)
+
+// InitTokenStore creates the token store backend selected by TOKEN_STORE (sql, memory or redis)
+func InitTokenStore(db *gorm.DB) (repositories.TokenStore, error) {
+	switch backend := GetEnv("TOKEN_STORE", "sql"); backend {
+	case "sql":
+		return repositories.NewTokenRepository(db), nil
+	case "memory":
+		return repositories.NewMemoryTokenStore(), nil
+	case "redis":
+		client, err := InitRedis()
+		if err != nil {
+			return nil, err
+		}
+		return repositories.NewRedisTokenStore(client, GetEnv("REDIS_KEY_PREFIX", "jwt:")), nil
+	default:
+		return nil, fmt.Errorf("unknown token store backend %q", backend)
+	}
+}
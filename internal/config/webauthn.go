@@ -0,0 +1,30 @@
+package config
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// InitWebAuthn creates the WebAuthn relying party used for passkeys
+func InitWebAuthn() (*webauthn.WebAuthn, error) {
+	var origins []string
+	for _, origin := range strings.Split(GetEnv("WEBAUTHN_RP_ORIGINS", GetEnv("APP_BASE_URL", "http://localhost:8080")), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+
+	timeout := time.Minute * time.Duration(GetEnvInt("WEBAUTHN_TIMEOUT", 5))
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          GetEnv("WEBAUTHN_RP_ID", "localhost"),
+		RPDisplayName: GetEnv("WEBAUTHN_RP_NAME", "JwtSecurityImplementation"),
+		RPOrigins:     origins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: timeout, TimeoutUVD: timeout},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: timeout, TimeoutUVD: timeout},
+		},
+	})
+}
@@ -7,8 +7,16 @@ import (
 	"JwtSecurityImplementation/repositories"
 	"JwtSecurityImplementation/routes"
 	"JwtSecurityImplementation/services"
+	"JwtSecurityImplementation/utils"
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -31,21 +39,125 @@ func main() {
 
 	// Initialize repositories
 	userRepo := repositories.NewUserRepository(db)
-	tokenRepo := repositories.NewTokenRepository(db)
+	patRepo := repositories.NewPersonalAccessTokenRepository(db)
+	oneTimeTokenRepo := repositories.NewOneTimeTokenRepository(db)
+	passwordHistoryRepo := repositories.NewPasswordHistoryRepository(db)
+	auditRepo := repositories.NewAuditRepository(db)
+	mfaRepo := repositories.NewMFARepository(db)
+	webAuthnCredentialRepo := repositories.NewWebAuthnCredentialRepository(db)
+	emailCodeRepo := repositories.NewEmailCodeRepository(db)
+	externalIdentityRepo := repositories.NewExternalIdentityRepository(db)
+	invitationRepo := repositories.NewInvitationRepository(db)
+
+	// Initialize the token store backend
+	tokenStore, err := config.InitTokenStore(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize token store: %v", err)
+	}
+
+	// Initialize mailer
+	mailer, err := config.InitMailer()
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Initialize the WebAuthn relying party for passkeys
+	webAuthn, err := config.InitWebAuthn()
+	if err != nil {
+		log.Fatalf("Failed to initialize WebAuthn: %v", err)
+	}
+
+	// Initialize the password backends tried at login, in AUTH_CHAIN order
+	authenticators, err := services.BuildAuthenticatorChain(config.GetEnv("AUTH_CHAIN", "local"), userRepo, config.InitLDAP())
+	if err != nil {
+		log.Fatalf("Failed to initialize authenticators: %v", err)
+	}
+
+	// Load the password peppers, kept outside the database
+	if err := utils.LoadPasswordPeppers(); err != nil {
+		log.Fatalf("Failed to load password peppers: %v", err)
+	}
+
+	// Load the keys TOTP secrets are encrypted with, kept outside the database
+	if err := utils.LoadTOTPEncryptionKeys(); err != nil {
+		log.Fatalf("Failed to load TOTP encryption keys: %v", err)
+	}
+
+	// Initialize the offline breach corpus new passwords are checked against
+	breachedPasswords, err := config.InitBreachedPasswords()
+	if err != nil {
+		log.Fatalf("Failed to load breached passwords: %v", err)
+	}
 
 	// Initialize services
-	authService := services.NewAuthService(userRepo)
-	tokenService := services.NewTokenService(tokenRepo)
+	auditService := services.NewAuditService(auditRepo)
+	oneTimeTokenService := services.NewOneTimeTokenService(oneTimeTokenRepo)
+	lockoutService := services.NewLockoutService(userRepo, auditService, oneTimeTokenService, mailer)
+	passwordPolicy := services.NewPasswordPolicy(passwordHistoryRepo, breachedPasswords)
+	invitationService := services.NewInvitationService(invitationRepo, auditService, mailer)
+	authService := services.NewAuthService(userRepo, lockoutService, passwordPolicy, invitationService, authenticators...)
+	tokenService := services.NewTokenService(tokenStore, userRepo, patRepo)
+	verificationService := services.NewEmailVerificationService(userRepo, oneTimeTokenService, mailer)
+	passwordService := services.NewPasswordService(userRepo, passwordHistoryRepo, passwordPolicy, tokenService, oneTimeTokenService, mailer)
+	adminService := services.NewAdminService(userRepo, webAuthnCredentialRepo, tokenService, passwordService, auditService)
+	emailCodeService := services.NewEmailCodeService(userRepo, emailCodeRepo, lockoutService, mailer)
+	mfaService := services.NewMFAService(userRepo, mfaRepo, tokenService, lockoutService, emailCodeService)
+	magicLinkService := services.NewMagicLinkService(userRepo, oneTimeTokenService, lockoutService, mailer)
+	oidcService := services.NewOIDCService(userRepo, externalIdentityRepo, lockoutService)
+	passkeyService := services.NewPasskeyService(webAuthn, userRepo, webAuthnCredentialRepo, tokenService, mfaService, lockoutService)
+
+	// Grant the admin role to the configured accounts
+	authService.BootstrapAdmins(strings.Split(config.GetEnv("ADMIN_EMAILS", ""), ","))
+
+	// Register access token claims providers here, e.g.
+	// tokenService.RegisterClaimsProvider(myProvider)
+
+	// Start background cleanup of expired revocation data
+	// Replicas sharing the SQL store elect a leader, other backends clean up per instance
+	var leaseRepo *repositories.LeaseRepository
+	if _, shared := tokenStore.(*repositories.TokenRepository); shared {
+		leaseRepo = repositories.NewLeaseRepository(db)
+	}
+	janitor := services.NewRevocationJanitor(tokenStore, leaseRepo)
+	if config.GetEnvBool("JANITOR_ENABLED", true) {
+		janitor.Start()
+	}
 
 	// Initialize controllers
-	authController := controllers.NewAuthController(authService, tokenService)
+	authController := controllers.NewAuthController(authService, tokenService, verificationService, lockoutService, mfaService)
+	tokenController := controllers.NewTokenController(tokenService)
+	passwordController := controllers.NewPasswordController(passwordService)
+	adminController := controllers.NewAdminController(lockoutService, adminService, invitationService)
+	mfaController := controllers.NewMFAController(mfaService, tokenService)
+	passkeyController := controllers.NewPasskeyController(passkeyService, tokenService)
+	magicLinkController := controllers.NewMagicLinkController(magicLinkService, tokenService, mfaService)
+	emailCodeController := controllers.NewEmailCodeController(emailCodeService, mfaService, tokenService)
+	oidcController := controllers.NewOIDCController(oidcService, tokenService, mfaService)
 
 	// Initialize middleware
 	jwtMiddleware := middleware.NewJWTMiddleware(tokenService)
 
+	rateLimitStore, err := config.InitRateLimitStore()
+	if err != nil {
+		log.Fatalf("Failed to initialize rate limit store: %v", err)
+	}
+	rateLimiter := middleware.NewRateLimiter(rateLimitStore)
+
 	// Setup Gin router
 	r := gin.New()
 
+	// Client IPs come from X-Forwarded-For only when a listed proxy sent the request,
+	// otherwise any client could pick the IP its rate limits are counted under
+	var trustedProxies []string
+	for _, proxy := range strings.Split(config.GetEnv("TRUSTED_PROXIES", ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	// Add recovery and logging middleware
 	r.Use(gin.Recovery())
 	r.Use(gin.Logger())
@@ -54,7 +166,15 @@ func main() {
 	// r.Use(middleware.CORSMiddleware())
 
 	// Setup routes
-	routes.SetupAuthRoutes(r, authController, jwtMiddleware)
+	routes.SetupAuthRoutes(r, authController, jwtMiddleware, rateLimiter)
+	routes.SetupTokenRoutes(r, tokenController, jwtMiddleware)
+	routes.SetupPasswordRoutes(r, passwordController, jwtMiddleware, rateLimiter)
+	routes.SetupAdminRoutes(r, adminController, jwtMiddleware)
+	routes.SetupMFARoutes(r, mfaController, jwtMiddleware, rateLimiter)
+	routes.SetupPasskeyRoutes(r, passkeyController, jwtMiddleware, rateLimiter)
+	routes.SetupMagicLinkRoutes(r, magicLinkController, rateLimiter)
+	routes.SetupEmailCodeRoutes(r, emailCodeController, jwtMiddleware, rateLimiter)
+	routes.SetupOIDCRoutes(r, oidcController, jwtMiddleware, rateLimiter)
 
 	// Start server
 	port := os.Getenv("SERVER_PORT")
@@ -62,8 +182,33 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Starting server on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: r,
+	}
+
+	go func() {
+		log.Printf("Starting server on port %s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Wait for an interrupt, then shut down gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	log.Println("Shutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server forced to shut down: %v", err)
+	}
+
+	if err := janitor.Stop(shutdownCtx); err != nil {
+		log.Printf("Revocation janitor did not stop cleanly: %v", err)
 	}
+	log.Printf("Revocation janitor removed %d rows in total", janitor.TotalRemoved())
 }
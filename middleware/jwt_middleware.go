@@ -17,7 +17,9 @@ func NewJWTMiddleware(tokenService *services.TokenService) *JWTMiddleware {
 	return &JWTMiddleware{tokenService: tokenService}
 }
 
-func (jm *JWTMiddleware) Authenticate() gin.HandlerFunc {
+// Authenticate admits interactive access tokens. Personal access tokens are only admitted
+// where scopes are given, and must carry every one of them.
+func (jm *JWTMiddleware) Authenticate(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -38,11 +40,144 @@ func (jm *JWTMiddleware) Authenticate() gin.HandlerFunc {
 			return
 		}
 
+		// Only access tokens and personal access tokens grant access to the API
+		tokenType, _ := claims["token_type"].(string)
+		switch {
+		case tokenType == "access":
+		case tokenType == "pat" && len(scopes) == 0:
+			responses.ErrorResponse(c, http.StatusForbidden, "Personal access tokens are not accepted here", nil)
+			c.Abort()
+			return
+		case tokenType == "pat":
+			if !hasScopes(claims, scopes) {
+				responses.ErrorResponse(c, http.StatusForbidden, "Insufficient token scope", nil)
+				c.Abort()
+				return
+			}
+		default:
+			responses.ErrorResponse(c, http.StatusUnauthorized, "Invalid token type", nil)
+			c.Abort()
+			return
+		}
+
+		userID, ok := claims["user_id"].(float64)
+		if !ok {
+			responses.ErrorResponse(c, http.StatusUnauthorized, "Invalid token", nil)
+			c.Abort()
+			return
+		}
+
 		// Set user claims in the context for further use
-		c.Set("user_id", claims["user_id"])
+		c.Set("user_id", uint(userID))
 		c.Set("email", claims["email"])
 		c.Set("token", token)
+		c.Set("token_type", tokenType)
+		if verified, ok := claims["email_verified"].(bool); ok {
+			c.Set("email_verified", verified)
+		}
+		if sessionID, ok := claims["sid"].(string); ok {
+			c.Set("session_id", sessionID)
+		}
+		if roles, ok := claims["roles"].([]interface{}); ok {
+			c.Set("roles", roles)
+		}
+
+		c.Next()
+	}
+}
+
+// hasScopes reports whether a personal access token carries every given scope
+func hasScopes(claims map[string]interface{}, scopes []string) bool {
+	granted := map[string]bool{}
+	if tokenScopes, ok := claims["scopes"].([]interface{}); ok {
+		for _, scope := range tokenScopes {
+			if s, ok := scope.(string); ok {
+				granted[s] = true
+			}
+		}
+	}
+
+	for _, scope := range scopes {
+		if !granted[scope] {
+			return false
+		}
+	}
+	return true
+}
+
+// RequireInteractiveSession rejects personal access tokens, e.g. for credential management
+func (jm *JWTMiddleware) RequireInteractiveSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("token_type") != "access" {
+			responses.ErrorResponse(c, http.StatusForbidden, "This action requires an interactive session", nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail keeps users who have not verified their email address away from
+// credential management, unless EMAIL_VERIFICATION_POLICY allows them everywhere
+func (jm *JWTMiddleware) RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if services.EmailVerificationPolicy() == services.EmailVerificationAllow {
+			c.Next()
+			return
+		}
+
+		// Personal access tokens can only be created from a verified session
+		if c.GetString("token_type") == "access" && !c.GetBool("email_verified") {
+			responses.ErrorResponse(c, http.StatusForbidden, "Email address has not been verified", nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole only lets users through whose access token carries the given role
+func (jm *JWTMiddleware) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if roles, ok := c.Get("roles"); ok {
+			for _, r := range roles.([]interface{}) {
+				if r == role {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		responses.ErrorResponse(c, http.StatusForbidden, "Insufficient permissions", nil)
+		c.Abort()
+	}
+}
+
+// RequireStepUp guards sensitive actions behind a recent re-verification.
+// The client sends the step-up token for its current session in the X-Step-Up-Token header;
+// the token is used up by the request.
+func (jm *JWTMiddleware) RequireStepUp() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := GetUserID(c)
+		err := jm.tokenService.ValidateStepUpToken(c.GetHeader("X-Step-Up-Token"), userID, c.GetString("session_id"))
+		if err != nil {
+			responses.ErrorResponse(c, http.StatusForbidden, "Step-up verification required", err)
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
 }
+
+// GetUserID returns the ID of the authenticated user stored by Authenticate
+func GetUserID(c *gin.Context) (uint, bool) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		return 0, false
+	}
+	id, ok := userID.(uint)
+	return id, ok
+}
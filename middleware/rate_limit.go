@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/pkg/ratelimit"
+	"JwtSecurityImplementation/pkg/responses"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPeekBodySize bounds how much of a request body is read to find the email address
+const maxPeekBodySize = 1 << 20
+
+type RateLimiter struct {
+	store ratelimit.Store
+}
+
+func NewRateLimiter(store ratelimit.Store) *RateLimiter {
+	return &RateLimiter{store: store}
+}
+
+// Limit throttles a route with token buckets per client IP, per email address in the
+// JSON body and for the route as a whole. Rules come from RATE_LIMIT_<ROUTE>_<IP|EMAIL|ROUTE>,
+// falling back to RATE_LIMIT_<IP|EMAIL|ROUTE>; "off" disables a bucket.
+func (rl *RateLimiter) Limit(route string) gin.HandlerFunc {
+	if !config.GetEnvBool("RATE_LIMIT_ENABLED", true) {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	ipRule := loadRateLimitRule(route, "IP", "20/1m")
+	emailRule := loadRateLimitRule(route, "EMAIL", "5/1m")
+	routeRule := loadRateLimitRule(route, "ROUTE", "100/1s")
+
+	return func(c *gin.Context) {
+		var results []ratelimit.Result
+		denied := false
+
+		take := func(key string, rule *ratelimit.Rule) {
+			if rule == nil {
+				return
+			}
+			result, err := rl.store.Take(key, *rule)
+			if err != nil {
+				// Fail open, a broken limiter store must not take the API down
+				log.Printf("Rate limiter: %v", err)
+				return
+			}
+			results = append(results, result)
+			denied = denied || !result.Allowed
+		}
+
+		take("ip:"+route+":"+c.ClientIP(), ipRule)
+		if emailRule != nil {
+			if email := peekEmail(c); email != "" {
+				take("email:"+route+":"+email, emailRule)
+			}
+		}
+		// The shared bucket is only spent on requests the client buckets allow,
+		// otherwise a single client could throttle the route for everyone
+		if !denied {
+			take("route:"+route, routeRule)
+		}
+
+		if len(results) == 0 {
+			c.Next()
+			return
+		}
+
+		// Report the most restrictive bucket
+		tightest := results[0]
+		for _, result := range results {
+			if !result.Allowed {
+				if result.RetryAfter > tightest.RetryAfter || tightest.Allowed {
+					tightest = result
+				}
+			} else if tightest.Allowed && result.Remaining < tightest.Remaining {
+				tightest = result
+			}
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(tightest.Reset)))
+
+		if denied {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(tightest.RetryAfter)))
+			responses.ErrorResponse(c, http.StatusTooManyRequests, "Too many requests, please try again later", nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// loadRateLimitRule reads the rule for one bucket of a route, nil if it is disabled
+func loadRateLimitRule(route, dimension, fallback string) *ratelimit.Rule {
+	generic := config.GetEnv("RATE_LIMIT_"+dimension, fallback)
+	value := config.GetEnv("RATE_LIMIT_"+strings.ToUpper(route)+"_"+dimension, generic)
+	if strings.EqualFold(value, "off") {
+		return nil
+	}
+
+	rule, err := ratelimit.ParseRule(value)
+	if err != nil {
+		log.Printf("Rate limiter: %v, using %s", err, fallback)
+		rule, _ = ratelimit.ParseRule(fallback)
+	}
+	return &rule
+}
+
+// peekEmail reads the "email" field from a JSON body and restores the body for the handler
+func peekEmail(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+
+	original := c.Request.Body
+	body, err := io.ReadAll(io.LimitReader(original, maxPeekBodySize))
+	// Put back what was read in front of the rest, larger bodies reach the handler whole
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), original), original}
+	if err != nil {
+		return ""
+	}
+
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(payload.Email))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"JwtSecurityImplementation/pkg/ratelimit"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newRateLimitedRouter serves POST /login behind the limiter for the given route, echoing the body
+func newRateLimitedRouter(store ratelimit.Store, route string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/login", NewRateLimiter(store).Limit(route), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, "%s", body)
+	})
+	return router
+}
+
+// postLogin sends a login request from the given address and returns the recorded response
+func postLogin(router *gin.Engine, remoteAddr, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteAddr
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLimitDeniedClientDoesNotDrainRouteBucket(t *testing.T) {
+	t.Setenv("RATE_LIMIT_IP", "2/1h")
+	t.Setenv("RATE_LIMIT_EMAIL", "off")
+	t.Setenv("RATE_LIMIT_ROUTE", "5/1h")
+
+	router := newRateLimitedRouter(ratelimit.NewMemoryStore(), "login")
+
+	for i := 0; i < 20; i++ {
+		postLogin(router, "192.0.2.1:1234", `{}`)
+	}
+
+	// The noisy client spent two route tokens, the others are still available
+	for i := 0; i < 2; i++ {
+		if rec := postLogin(router, "192.0.2.2:1234", `{}`); rec.Code != http.StatusOK {
+			t.Fatalf("request %d from another client: got %d, want %d", i, rec.Code, http.StatusOK)
+		}
+	}
+	if rec := postLogin(router, "192.0.2.3:1234", `{}`); rec.Code != http.StatusOK {
+		t.Errorf("route bucket drained by denied requests: got %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestLimitReportsRateLimitHeaders(t *testing.T) {
+	t.Setenv("RATE_LIMIT_IP", "3/1m")
+	t.Setenv("RATE_LIMIT_EMAIL", "off")
+	t.Setenv("RATE_LIMIT_ROUTE", "off")
+
+	router := newRateLimitedRouter(ratelimit.NewMemoryStore(), "login")
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		rec := postLogin(router, "192.0.2.1:1234", `{}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request within the limit: got %d, want %d", rec.Code, http.StatusOK)
+		}
+		if got := rec.Header().Get("RateLimit-Limit"); got != "3" {
+			t.Errorf("RateLimit-Limit = %q, want 3", got)
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != strconv.Itoa(remaining) {
+			t.Errorf("RateLimit-Remaining = %q, want %d", got, remaining)
+		}
+		if rec.Header().Get("Retry-After") != "" {
+			t.Error("Retry-After sent on an allowed request")
+		}
+	}
+
+	rec := postLogin(router, "192.0.2.1:1234", `{}`)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit: got %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	// One token comes back every 20 seconds, the whole bucket within the minute
+	if got := rec.Header().Get("Retry-After"); got != "20" {
+		t.Errorf("Retry-After = %q, want 20", got)
+	}
+	if reset, err := strconv.Atoi(rec.Header().Get("RateLimit-Reset")); err != nil || reset < 59 || reset > 60 {
+		t.Errorf("RateLimit-Reset = %q, want about 60", rec.Header().Get("RateLimit-Reset"))
+	}
+}
+
+func TestLimitThrottlesPerEmailAndKeepsBody(t *testing.T) {
+	t.Setenv("RATE_LIMIT_IP", "off")
+	t.Setenv("RATE_LIMIT_EMAIL", "2/1h")
+	t.Setenv("RATE_LIMIT_ROUTE", "off")
+
+	router := newRateLimitedRouter(ratelimit.NewMemoryStore(), "login")
+
+	body := `{"email":"Jane@Example.com","password":"secret"}`
+	for i := 0; i < 2; i++ {
+		rec := postLogin(router, "192.0.2."+strconv.Itoa(i+1)+":1234", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: got %d, want %d", i, rec.Code, http.StatusOK)
+		}
+		if rec.Body.String() != body {
+			t.Fatalf("handler read %q, want the original body", rec.Body.String())
+		}
+	}
+
+	// The address is compared case-insensitively, whichever client sends it
+	if rec := postLogin(router, "192.0.2.9:1234", `{"email":"jane@example.com"}`); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("third request for the same email: got %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec := postLogin(router, "192.0.2.9:1234", `{"email":"john@example.com"}`); rec.Code != http.StatusOK {
+		t.Errorf("request for another email: got %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+// failingStore stands in for an unreachable limiter backend
+type failingStore struct{}
+
+func (failingStore) Take(string, ratelimit.Rule) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestLimitFailsOpenOnStoreErrors(t *testing.T) {
+	t.Setenv("RATE_LIMIT_IP", "1/1h")
+	t.Setenv("RATE_LIMIT_EMAIL", "1/1h")
+	t.Setenv("RATE_LIMIT_ROUTE", "1/1h")
+
+	router := newRateLimitedRouter(failingStore{}, "login")
+
+	for i := 0; i < 3; i++ {
+		rec := postLogin(router, "192.0.2.1:1234", `{"email":"jane@example.com"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d with a broken store: got %d, want %d", i, rec.Code, http.StatusOK)
+		}
+		if rec.Header().Get("RateLimit-Limit") != "" {
+			t.Error("RateLimit headers sent without a bucket result")
+		}
+	}
+}
+
+func TestLimitDisabled(t *testing.T) {
+	t.Setenv("RATE_LIMIT_ENABLED", "false")
+	t.Setenv("RATE_LIMIT_IP", "1/1h")
+
+	router := newRateLimitedRouter(ratelimit.NewMemoryStore(), "login")
+	for i := 0; i < 3; i++ {
+		if rec := postLogin(router, "192.0.2.1:1234", `{}`); rec.Code != http.StatusOK {
+			t.Fatalf("request %d with rate limiting disabled: got %d, want %d", i, rec.Code, http.StatusOK)
+		}
+	}
+}
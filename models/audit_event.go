@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Audit event types
+const (
+	AuditAccountLocked   = "account.locked"
+	AuditAccountUnlocked = "account.unlocked"
+
+	// Administrator actions on a user
+	AuditAccountSuspended    = "account.suspended"
+	AuditAccountReactivated  = "account.reactivated"
+	AuditPasswordResetForced = "password.reset_forced"
+	AuditSessionsRevoked     = "sessions.revoked"
+	AuditRolesChanged        = "roles.changed"
+	AuditPasskeyCloneCleared = "passkey.clone_warning_cleared"
+	AuditPasskeyDeleted      = "passkey.deleted"
+
+	// Invitations to register
+	AuditInvitationCreated  = "invitation.created"
+	AuditInvitationRevoked  = "invitation.revoked"
+	AuditInvitationAccepted = "invitation.accepted"
+)
+
+// AuditEvent records a security-relevant action for later review
+type AuditEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Type      string    `gorm:"type:varchar(50);index;not null" json:"type"`
+	UserID    *uint     `gorm:"index" json:"userId,omitempty"`
+	ActorID   *uint     `gorm:"index" json:"actorId,omitempty"`
+	IPAddress string    `gorm:"type:varchar(45)" json:"ipAddress,omitempty"`
+	Details   string    `gorm:"type:nvarchar(max)" json:"details,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// EmailCode is a short numeric code sent by email for login, MFA or step-up verification.
+// Only an HMAC of the code is stored, and every verification attempt is counted.
+type EmailCode struct {
+	ID        string    `gorm:"type:varchar(36);primaryKey"`
+	UserID    uint      `gorm:"index;not null"`
+	Purpose   string    `gorm:"type:varchar(50);index;not null"`
+	CodeHash  string    `gorm:"type:varchar(64);not null"`
+	Attempts  int       `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ExternalIdentity links a user to an account at an upstream OpenID Connect provider.
+// Provider and Subject together identify the upstream account.
+type ExternalIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index;not null" json:"-"`
+	Provider    string     `gorm:"type:varchar(50);not null;uniqueIndex:idx_external_identity_subject" json:"provider"`
+	Subject     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_external_identity_subject" json:"-"`
+	Email       string     `gorm:"type:varchar(255)" json:"email"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
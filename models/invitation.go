@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Invitation lets someone register while registration is restricted, with roles and a tenant
+// assigned up front. Only its metadata is stored; the signed code is shown once at creation.
+type Invitation struct {
+	ID string `gorm:"type:varchar(36);primaryKey" json:"id"`
+	// Email optionally restricts the invitation to one address
+	Email        string     `gorm:"type:varchar(100);index" json:"email,omitempty"`
+	Roles        []string   `gorm:"serializer:json;type:varchar(500)" json:"roles"`
+	Tenant       string     `gorm:"type:varchar(100)" json:"tenant,omitempty"`
+	CreatedByID  uint       `gorm:"index;not null" json:"createdById"`
+	ExpiresAt    time.Time  `gorm:"index;not null" json:"expiresAt"`
+	AcceptedAt   *time.Time `json:"acceptedAt,omitempty"`
+	AcceptedByID *uint      `json:"acceptedById,omitempty"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// IsPending reports whether the invitation can still be used to register
+func (i *Invitation) IsPending() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && i.ExpiresAt.After(time.Now())
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Lease is a named lock with an expiry, used to elect a single leader among replicas
+type Lease struct {
+	Name      string    `gorm:"type:varchar(100);primaryKey"`
+	Holder    string    `gorm:"type:varchar(255);not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
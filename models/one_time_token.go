@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// OneTimeToken records a single-use link token, e.g. for email verification.
+// The signed token itself is only sent to the user; its ID is the JWT's jti.
+// Binding optionally holds a hash of a secret the token must be presented with.
+type OneTimeToken struct {
+	ID        string    `gorm:"type:varchar(36);primaryKey"`
+	UserID    uint      `gorm:"index;not null"`
+	Purpose   string    `gorm:"type:varchar(50);index;not null"`
+	Binding   string    `gorm:"type:varchar(64);not null;default:''"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// PasswordHistory keeps a previous password hash of a user to prevent reuse
+type PasswordHistory struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index;not null"`
+	Hash      string    `gorm:"type:varchar(255);not null"`
+	CreatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// PersonalAccessToken is a long-lived, scoped token created by a user for CLIs and scripts.
+// Only its metadata is stored; the signed token itself is shown once at creation.
+type PersonalAccessToken struct {
+	ID         string     `gorm:"type:varchar(36);primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Scopes     []string   `gorm:"serializer:json;type:varchar(500);not null" json:"scopes"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// IsActive reports whether the token can still be used
+func (t *PersonalAccessToken) IsActive() bool {
+	return t.RevokedAt == nil && t.ExpiresAt.After(time.Now())
+}
+
+// HasScope reports whether the token was granted the given scope
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RecoveryCode is a one-time MFA backup code, stored as a SHA-256 hash
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
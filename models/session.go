@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Session represents a login session backed by a refresh token
+type Session struct {
+	ID         string     `gorm:"type:varchar(36);primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	RememberMe bool       `gorm:"not null" json:"rememberMe"`
+	UserAgent  string     `gorm:"type:varchar(255)" json:"userAgent"`
+	IPAddress  string     `gorm:"type:varchar(45)" json:"ipAddress"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expiresAt"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// ClientInfo describes the client a session is issued to
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// IsActive reports whether the session can still be used
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(time.Now())
+}
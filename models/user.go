@@ -5,13 +5,80 @@ import (
 	"time"
 )
 
+// Account states stored in User.Status
+const (
+	UserStatusActive              = "active"
+	UserStatusSuspended           = "suspended"
+	UserStatusLocked              = "locked"
+	UserStatusPendingVerification = "pending_verification"
+)
+
 type User struct {
 	gorm.Model
-	FirstName       string `gorm:"type:varchar(100);not null" json:"firstName" validate:"required"`
-	LastName        string `gorm:"type:varchar(100);not null" json:"lastName" validate:"required"`
-	Email           string `gorm:"type:varchar(100);uniqueIndex;not null" json:"email" validate:"required,email"`
-	Password        string `gorm:"type:varchar(255);not null" json:"-"`
-	ConfirmPassword string `gorm:"-" json:"confirmPassword" validate:"required,eqfield=Password"`
+	FirstName       string     `gorm:"type:varchar(100);not null" json:"firstName" validate:"required"`
+	LastName        string     `gorm:"type:varchar(100);not null" json:"lastName" validate:"required"`
+	Email           string     `gorm:"type:varchar(100);uniqueIndex;not null" json:"email" validate:"required,email"`
+	Password        string     `gorm:"type:varchar(255);not null" json:"-"`
+	ConfirmPassword string     `gorm:"-" json:"-" validate:"required,eqfield=Password"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	Roles           []string   `gorm:"serializer:json;type:varchar(500)" json:"roles"`
+	// Tenant the user belongs to, carried in access tokens
+	Tenant string `gorm:"type:varchar(100);index" json:"tenant,omitempty"`
+
+	// Password age for PASSWORD_MAX_AGE, older rows fall back to CreatedAt
+	PasswordChangedAt *time.Time `json:"-"`
+	// Set by an administrator, cleared when the password is next changed
+	PasswordResetRequired bool `gorm:"not null;default:0" json:"passwordResetRequired"`
+
+	// Account state, kept in step with verification, lockouts and suspensions
+	Status          string     `gorm:"type:varchar(32);not null;default:active;index" json:"status"`
+	StatusReason    string     `gorm:"type:varchar(255)" json:"statusReason,omitempty"`
+	StatusChangedAt *time.Time `json:"statusChangedAt,omitempty"`
+
+	// Account lockout state
+	FailedLoginAttempts int        `gorm:"not null;default:0" json:"-"`
+	LockoutCount        int        `gorm:"not null;default:0" json:"-"`
+	LockedUntil         *time.Time `json:"lockedUntil,omitempty"`
+
+	// Two-factor authentication state, the secret is pending until MFAEnabled is set
+	MFAEnabled   bool   `gorm:"not null;default:0" json:"mfaEnabled"`
+	TOTPSecret   string `gorm:"type:varchar(128)" json:"-"`
+	TOTPLastStep int64  `gorm:"not null;default:0" json:"-"`
+}
+
+// HasRole reports whether the user has been granted the given role
+func (u *User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSuspended reports whether an administrator has suspended the account
+func (u *User) IsSuspended() bool {
+	return u.Status == UserStatusSuspended
+}
+
+// IsLocked reports whether the account is currently locked out
+func (u *User) IsLocked() bool {
+	return u.LockedUntil != nil && u.LockedUntil.After(time.Now())
+}
+
+// AfterFind reports the lockout as it is now. The stored status only leaves locked
+// at the next successful login, after the lockout has long expired.
+func (u *User) AfterFind(tx *gorm.DB) error {
+	switch {
+	case u.Status == UserStatusSuspended:
+	case u.IsLocked():
+		u.Status = UserStatusLocked
+	case u.Status == UserStatusLocked && u.EmailVerifiedAt == nil:
+		u.Status = UserStatusPendingVerification
+	case u.Status == UserStatusLocked:
+		u.Status = UserStatusActive
+	}
+	return nil
 }
 
 type TokenDetails struct {
@@ -19,9 +86,17 @@ type TokenDetails struct {
 	RefreshToken       string
 	AccessTokenExpiry  time.Time
 	RefreshTokenExpiry time.Time
+	SessionID          string
+	RememberMe         bool
 }
 
+// BlacklistedToken stores the SHA-256 hash of a revoked token until it expires.
+// It replaces the blacklisted_tokens table of raw tokens, see repositories.MigrateLegacyBlacklist.
 type BlacklistedToken struct {
-	Token     string `gorm:"primaryKey"`
-	ExpiresAt time.Time
+	Token     string    `gorm:"type:varchar(64);primaryKey"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+func (BlacklistedToken) TableName() string {
+	return "blacklisted_token_hashes"
 }
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// WebAuthnCredential is a passkey or security key registered by a user.
+// The public key and sign counter are used to verify every assertion.
+type WebAuthnCredential struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"index;not null" json:"-"`
+	Name            string     `gorm:"type:varchar(100);not null" json:"name"`
+	CredentialID    string     `gorm:"type:varchar(255);uniqueIndex;not null" json:"credentialId"`
+	PublicKey       []byte     `gorm:"not null" json:"-"`
+	AttestationType string     `gorm:"type:varchar(32)" json:"-"`
+	AAGUID          []byte     `gorm:"type:varbinary(16)" json:"-"`
+	Transports      []string   `gorm:"serializer:json;type:varchar(200)" json:"transports"`
+	SignCount       uint32     `gorm:"not null;default:0" json:"-"`
+	BackupEligible  bool       `gorm:"not null;default:0" json:"backupEligible"`
+	BackupState     bool       `gorm:"not null;default:0" json:"backupState"`
+	CloneWarning    bool       `gorm:"not null;default:0" json:"cloneWarning"`
+	LastUsedAt      *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,161 @@
+package ldapauth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	// ErrUserNotFound is returned when no directory entry matches the login name
+	ErrUserNotFound = errors.New("user not found in directory")
+	// ErrInvalidCredentials is returned when the directory rejects the password
+	ErrInvalidCredentials = errors.New("invalid directory credentials")
+)
+
+// Config describes how to reach the directory and find users in it
+type Config struct {
+	// URL of the server, ldap:// or ldaps://
+	URL string
+	// StartTLS upgrades a plain ldap:// connection before binding
+	StartTLS           bool
+	InsecureSkipVerify bool
+
+	// BindDN and BindPassword are the service account used to search for users
+	BindDN       string
+	BindPassword string
+
+	BaseDN string
+	// UserFilter finds a user by login name; %s is replaced by the escaped name,
+	// e.g. (&(objectClass=user)(|(mail=%s)(userPrincipalName=%s)))
+	UserFilter string
+
+	EmailAttribute     string
+	FirstNameAttribute string
+	LastNameAttribute  string
+	GroupAttribute     string
+
+	Timeout time.Duration
+}
+
+// Entry is the directory user a login resolved to
+type Entry struct {
+	DN        string
+	Email     string
+	FirstName string
+	LastName  string
+	Groups    []string
+}
+
+// Client authenticates users against an LDAP or Active Directory server
+type Client struct {
+	config Config
+}
+
+func NewClient(config Config) *Client {
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "mail"
+	}
+	if config.FirstNameAttribute == "" {
+		config.FirstNameAttribute = "givenName"
+	}
+	if config.LastNameAttribute == "" {
+		config.LastNameAttribute = "sn"
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &Client{config: config}
+}
+
+// Authenticate looks the user up with the service account, then binds as the user to check the password.
+// A wrong password for an existing user returns its entry along with ErrInvalidCredentials,
+// so the caller can tell whose password was wrong.
+func (c *Client) Authenticate(login, password string) (*Entry, error) {
+	// An empty password would be an unauthenticated bind, which many servers accept
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if c.config.BindDN != "" {
+		if err := conn.Bind(c.config.BindDN, c.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("service account bind failed: %w", err)
+		}
+	}
+
+	escaped := ldap.EscapeFilter(login)
+	filter := strings.ReplaceAll(c.config.UserFilter, "%s", escaped)
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		c.config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(c.config.Timeout.Seconds()), false,
+		filter,
+		[]string{c.config.EmailAttribute, c.config.FirstNameAttribute, c.config.LastNameAttribute, c.config.GroupAttribute},
+		nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("user search failed: %w", err)
+	}
+	if result == nil || len(result.Entries) == 0 {
+		return nil, ErrUserNotFound
+	}
+	// An ambiguous filter must never pick one of several users
+	if len(result.Entries) > 1 {
+		return nil, fmt.Errorf("login %q matches more than one directory entry", login)
+	}
+
+	found := result.Entries[0]
+	entry := &Entry{
+		DN:        found.DN,
+		Email:     found.GetAttributeValue(c.config.EmailAttribute),
+		FirstName: found.GetAttributeValue(c.config.FirstNameAttribute),
+		LastName:  found.GetAttributeValue(c.config.LastNameAttribute),
+		Groups:    found.GetAttributeValues(c.config.GroupAttribute),
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return entry, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("user bind failed: %w", err)
+	}
+
+	return entry, nil
+}
+
+func (c *Client) connect() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.config.InsecureSkipVerify}
+	if u, err := url.Parse(c.config.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := ldap.DialURL(c.config.URL,
+		ldap.DialWithTLSConfig(tlsConfig),
+		ldap.DialWithDialer(&net.Dialer{Timeout: c.config.Timeout}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to directory: %w", err)
+	}
+	conn.SetTimeout(c.config.Timeout)
+
+	if c.config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,130 @@
+package ldapauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"JwtSecurityImplementation/pkg/ldapauth/ldaptest"
+)
+
+const (
+	testServiceDN = "cn=service,ou=system,dc=example,dc=com"
+	testUserDN    = "cn=Jane Doe,ou=people,dc=example,dc=com"
+)
+
+func newTestDirectory(t *testing.T, extra ...ldaptest.Entry) (*ldaptest.Server, *Client) {
+	entries := append([]ldaptest.Entry{
+		{DN: testServiceDN, Password: "service-secret"},
+		{
+			DN:       testUserDN,
+			Password: "user-secret",
+			Attributes: map[string][]string{
+				"objectClass":       {"user"},
+				"mail":              {"jane@example.com"},
+				"userPrincipalName": {"jdoe@corp.example.com"},
+				"givenName":         {"Jane"},
+				"sn":                {"Doe"},
+				"memberOf":          {"cn=admins,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"},
+			},
+		},
+	}, extra...)
+	server := ldaptest.NewServer(t, entries...)
+
+	client := NewClient(Config{
+		URL:          server.URL,
+		BindDN:       testServiceDN,
+		BindPassword: "service-secret",
+		BaseDN:       "dc=example,dc=com",
+		UserFilter:   "(&(objectClass=user)(|(mail=%s)(userPrincipalName=%s)))",
+		Timeout:      5 * time.Second,
+	})
+	return server, client
+}
+
+func TestAuthenticateBindsAsTheUser(t *testing.T) {
+	for _, login := range []string{"jane@example.com", "jdoe@corp.example.com"} {
+		t.Run(login, func(t *testing.T) {
+			server, client := newTestDirectory(t)
+
+			entry, err := client.Authenticate(login, "user-secret")
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if entry.DN != testUserDN || entry.Email != "jane@example.com" || entry.FirstName != "Jane" || entry.LastName != "Doe" {
+				t.Errorf("Authenticate returned %+v, want Jane Doe's entry", entry)
+			}
+			if len(entry.Groups) != 2 || entry.Groups[0] != "cn=admins,ou=groups,dc=example,dc=com" {
+				t.Errorf("groups = %v, want both memberOf values", entry.Groups)
+			}
+
+			// The service account searches, then the user's own bind checks the password
+			if binds := server.Binds(); len(binds) != 2 || binds[0] != testServiceDN || binds[1] != testUserDN {
+				t.Errorf("binds = %v, want the service account then the user", binds)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWrongPasswordReturnsTheEntry(t *testing.T) {
+	_, client := newTestDirectory(t)
+
+	entry, err := client.Authenticate("jdoe@corp.example.com", "wrong")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate returned %v, want ErrInvalidCredentials", err)
+	}
+	if entry == nil || entry.Email != "jane@example.com" {
+		t.Errorf("Authenticate returned entry %+v, want Jane Doe's so the failure can be counted", entry)
+	}
+}
+
+func TestAuthenticateUnknownUser(t *testing.T) {
+	_, client := newTestDirectory(t)
+
+	for _, login := range []string{"nobody@example.com", "*"} {
+		if _, err := client.Authenticate(login, "user-secret"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("Authenticate(%q) returned %v, want ErrUserNotFound", login, err)
+		}
+	}
+}
+
+func TestAuthenticateRefusesEmptyPasswords(t *testing.T) {
+	server, client := newTestDirectory(t)
+
+	if _, err := client.Authenticate("jane@example.com", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate returned %v, want ErrInvalidCredentials", err)
+	}
+	if binds := server.Binds(); len(binds) != 0 {
+		t.Errorf("binds = %v, want none for an empty password", binds)
+	}
+}
+
+func TestAuthenticateRefusesAmbiguousLogins(t *testing.T) {
+	// Another entry claims Jane's principal name as its address
+	server, client := newTestDirectory(t, ldaptest.Entry{
+		DN:       "cn=Impostor,ou=people,dc=example,dc=com",
+		Password: "user-secret",
+		Attributes: map[string][]string{
+			"objectClass": {"user"},
+			"mail":        {"jdoe@corp.example.com"},
+		},
+	})
+
+	_, err := client.Authenticate("jdoe@corp.example.com", "user-secret")
+	if err == nil || errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Authenticate returned %v, want an ambiguity error", err)
+	}
+	if binds := server.Binds(); len(binds) != 1 {
+		t.Errorf("binds = %v, want only the service account", binds)
+	}
+}
+
+func TestAuthenticateServiceAccountFailure(t *testing.T) {
+	_, client := newTestDirectory(t)
+	client.config.BindPassword = "wrong"
+
+	_, err := client.Authenticate("jane@example.com", "user-secret")
+	if err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate returned %v, want a service account error", err)
+	}
+}
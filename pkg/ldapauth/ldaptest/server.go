@@ -0,0 +1,279 @@
+// Package ldaptest runs a minimal in-process LDAP server for tests. It understands simple binds,
+// subtree searches with and, or, not, equality and presence filters, and unbinds.
+package ldaptest
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// LDAP result codes the server answers with
+const (
+	resultSuccess            = 0
+	resultProtocolError      = 2
+	resultSizeLimitExceeded  = 4
+	resultInvalidCredentials = 49
+	resultUnwillingToPerform = 53
+)
+
+// Protocol operation tags of RFC 4511
+const (
+	applicationBindRequest   = 0
+	applicationBindResponse  = 1
+	applicationUnbindRequest = 2
+	applicationSearchRequest = 3
+	applicationSearchEntry   = 4
+	applicationSearchDone    = 5
+)
+
+// Filter choice tags, and the tag of a simple bind's password
+const (
+	filterAnd           = 0
+	filterOr            = 1
+	filterNot           = 2
+	filterEqualityMatch = 3
+	filterPresent       = 7
+
+	authenticationSimple = 0
+)
+
+// Entry is a directory object. Attribute names are compared case-insensitively.
+type Entry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// Server is an LDAP server listening on a loopback port until the test ends
+type Server struct {
+	URL string
+
+	listener net.Listener
+	mu       sync.Mutex
+	entries  []Entry
+	binds    []string
+}
+
+// NewServer starts a server holding the given entries
+func NewServer(t *testing.T, entries ...Entry) *Server {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ldaptest: listen: %v", err)
+	}
+
+	server := &Server{URL: "ldap://" + listener.Addr().String(), listener: listener, entries: entries}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	return server
+}
+
+// Binds returns the DNs of every successful bind so far, in order
+func (s *Server) Binds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+// SetAttribute replaces the values of an attribute of the entry with the given DN
+func (s *Server) SetAttribute(dn, name string, values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, entry := range s.entries {
+		if !strings.EqualFold(entry.DN, dn) {
+			continue
+		}
+
+		attributes := map[string][]string{}
+		for attribute, existing := range entry.Attributes {
+			if !strings.EqualFold(attribute, name) {
+				attributes[attribute] = existing
+			}
+		}
+		attributes[name] = values
+		s.entries[i].Attributes = attributes
+	}
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case applicationBindRequest:
+			code := s.bind(request)
+			writeResponse(conn, messageID, applicationBindResponse, code)
+		case applicationSearchRequest:
+			code := s.search(conn, messageID, request)
+			writeResponse(conn, messageID, applicationSearchDone, code)
+		case applicationUnbindRequest:
+			return
+		default:
+			writeResponse(conn, messageID, applicationSearchDone, resultProtocolError)
+		}
+	}
+}
+
+// bind checks a simple bind against the stored passwords
+func (s *Server) bind(request *ber.Packet) int {
+	if len(request.Children) < 3 || request.Children[2].Tag != authenticationSimple {
+		return resultUnwillingToPerform
+	}
+	dn := request.Children[1].Data.String()
+	password := request.Children[2].Data.String()
+	if password == "" {
+		return resultUnwillingToPerform
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) && entry.Password == password {
+			s.binds = append(s.binds, entry.DN)
+			return resultSuccess
+		}
+	}
+	return resultInvalidCredentials
+}
+
+// search writes the entries below the base DN that match the filter, honouring the size limit
+func (s *Server) search(conn net.Conn, messageID interface{}, request *ber.Packet) int {
+	if len(request.Children) < 8 {
+		return resultProtocolError
+	}
+	baseDN := strings.ToLower(request.Children[0].Data.String())
+	sizeLimit, _ := request.Children[3].Value.(int64)
+	filter := request.Children[6]
+
+	var requested []string
+	for _, attribute := range request.Children[7].Children {
+		requested = append(requested, attribute.Data.String())
+	}
+
+	s.mu.Lock()
+	entries := append([]Entry(nil), s.entries...)
+	s.mu.Unlock()
+
+	sent := 0
+	for _, entry := range entries {
+		if !strings.HasSuffix(strings.ToLower(entry.DN), baseDN) || !matches(filter, entry) {
+			continue
+		}
+		if sizeLimit > 0 && int64(sent) >= sizeLimit {
+			return resultSizeLimitExceeded
+		}
+		conn.Write(envelope(messageID, searchEntry(entry, requested)).Bytes())
+		sent++
+	}
+	return resultSuccess
+}
+
+// matches evaluates a search filter against an entry
+func matches(filter *ber.Packet, entry Entry) bool {
+	switch filter.Tag {
+	case filterAnd:
+		for _, child := range filter.Children {
+			if !matches(child, entry) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, child := range filter.Children {
+			if matches(child, entry) {
+				return true
+			}
+		}
+		return false
+	case filterNot:
+		return len(filter.Children) == 1 && !matches(filter.Children[0], entry)
+	case filterEqualityMatch:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		want := filter.Children[1].Data.String()
+		for _, value := range entry.values(filter.Children[0].Data.String()) {
+			if strings.EqualFold(value, want) {
+				return true
+			}
+		}
+		return false
+	case filterPresent:
+		return len(entry.values(filter.Data.String())) > 0
+	default:
+		return false
+	}
+}
+
+// values returns the values of an attribute, matching its name case-insensitively
+func (e Entry) values(name string) []string {
+	for attribute, values := range e.Attributes {
+		if strings.EqualFold(attribute, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func searchEntry(entry Entry, requested []string) *ber.Packet {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, applicationSearchEntry, nil, "Search Result Entry")
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "Object Name"))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, name := range requested {
+		values := entry.values(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	response.AppendChild(attributes)
+
+	return response
+}
+
+func writeResponse(conn net.Conn, messageID interface{}, application ber.Tag, code int) {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, application, nil, "Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	conn.Write(envelope(messageID, response).Bytes())
+}
+
+func envelope(messageID interface{}, operation *ber.Packet) *ber.Packet {
+	message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Message")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	message.AppendChild(operation)
+	return message
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// LogMailer writes messages to a writer instead of sending them, for development and testing
+type LogMailer struct {
+	mu     sync.Mutex
+	writer io.Writer
+	from   string
+}
+
+// NewLogMailer creates a LogMailer that writes to the standard logger
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{writer: log.Writer(), from: from}
+}
+
+// NewFileMailer creates a LogMailer that appends messages to the file at path
+func NewFileMailer(path, from string) (*LogMailer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mail file: %w", err)
+	}
+	return &LogMailer{writer: file, from: from}, nil
+}
+
+// Send writes the formatted message followed by a separator line
+func (m *LogMailer) Send(message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.writer.Write(format(m.from, message)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(m.writer, "\r\n----------------------------------------\r\n")
+	return err
+}
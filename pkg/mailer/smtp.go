@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"net"
+	"net/smtp"
+)
+
+// SMTPMailer sends email through an SMTP server, using STARTTLS when the server offers it
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer creates a new instance of SMTPMailer
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// Send delivers the message through the configured SMTP server
+func (m *SMTPMailer) Send(message Message) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	return smtp.SendMail(net.JoinHostPort(m.host, m.port), auth, m.from,
+		[]string{sanitizeHeader(message.To)}, format(m.from, message))
+}
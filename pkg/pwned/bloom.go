@@ -0,0 +1,130 @@
+package pwned
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// bloomMagic starts every filter file, followed by the bit count, the hash count and the bits
+var bloomMagic = [8]byte{'P', 'W', 'N', 'D', 'B', 'L', 'M', '1'}
+
+// BloomFilter is a compact set of breached SHA-1 hashes. It never misses a hash that was
+// added but may report one that was not, at the false positive rate it was sized for.
+// Counts are not kept, so the threshold is applied when the filter is built.
+type BloomFilter struct {
+	bits   []uint64
+	size   uint64
+	hashes uint32
+}
+
+// NewBloomFilter sizes a filter for n hashes at the given false positive rate
+func NewBloomFilter(n uint64, falsePositiveRate float64) (*BloomFilter, error) {
+	if n == 0 {
+		n = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, errors.New("false positive rate must be between 0 and 1")
+	}
+
+	size := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	size = (size + 63) / 64 * 64
+	hashes := uint32(math.Max(1, math.Round(float64(size)/float64(n)*math.Ln2)))
+
+	return &BloomFilter{bits: make([]uint64, size/64), size: size, hashes: hashes}, nil
+}
+
+// Add inserts a SHA-1 hash
+func (f *BloomFilter) Add(hash [20]byte) {
+	h1, h2 := splitHash(hash)
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (h1 + i*h2) % f.size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Contains reports whether a SHA-1 hash may have been added
+func (f *BloomFilter) Contains(hash [20]byte) bool {
+	h1, h2 := splitHash(hash)
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (h1 + i*h2) % f.size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Breached reports whether the password may be in the filter
+func (f *BloomFilter) Breached(password string) (bool, error) {
+	return f.Contains(sha1.Sum([]byte(password))), nil
+}
+
+// WriteTo stores the filter in the format ReadBloomFilter loads
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	out := bufio.NewWriter(w)
+	header := make([]byte, 0, 20)
+	header = append(header, bloomMagic[:]...)
+	header = binary.LittleEndian.AppendUint64(header, f.size)
+	header = binary.LittleEndian.AppendUint32(header, f.hashes)
+	if _, err := out.Write(header); err != nil {
+		return 0, err
+	}
+
+	word := make([]byte, 8)
+	for _, bits := range f.bits {
+		binary.LittleEndian.PutUint64(word, bits)
+		if _, err := out.Write(word); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(header) + 8*len(f.bits)), out.Flush()
+}
+
+// ReadBloomFilter loads a filter written by WriteTo
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	in := bufio.NewReader(r)
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return nil, fmt.Errorf("invalid bloom filter: %w", err)
+	}
+	if [8]byte(header[:8]) != bloomMagic {
+		return nil, errors.New("invalid bloom filter: unknown format")
+	}
+
+	size := binary.LittleEndian.Uint64(header[8:16])
+	hashes := binary.LittleEndian.Uint32(header[16:20])
+	if size == 0 || size%64 != 0 || hashes == 0 {
+		return nil, errors.New("invalid bloom filter: bad header")
+	}
+
+	f := &BloomFilter{bits: make([]uint64, size/64), size: size, hashes: hashes}
+	word := make([]byte, 8)
+	for i := range f.bits {
+		if _, err := io.ReadFull(in, word); err != nil {
+			return nil, fmt.Errorf("invalid bloom filter: %w", err)
+		}
+		f.bits[i] = binary.LittleEndian.Uint64(word)
+	}
+	return f, nil
+}
+
+// LoadBloomFilter reads a filter file into memory
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadBloomFilter(file)
+}
+
+// splitHash derives the two hashes for double hashing from the SHA-1, which is already uniform
+func splitHash(hash [20]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(hash[0:8]), binary.BigEndian.Uint64(hash[8:16]) | 1
+}
@@ -0,0 +1,228 @@
+package pwned
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Files sorted by hash are searched by bisection down to a window this size, then scanned
+const scanWindow = 64 * 1024
+
+// FileChecker looks passwords up in HIBP data. Path is either a file of "HASH:COUNT" lines
+// sorted by hash, or a directory of range files named by the 5-character hash prefix
+// holding "SUFFIX:COUNT" lines, as written by the PwnedPasswordsDownloader.
+type FileChecker struct {
+	path      string
+	threshold int
+	directory bool
+}
+
+// NewFileChecker refuses passwords seen at least threshold times
+func NewFileChecker(path string, threshold int) (*FileChecker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &FileChecker{path: path, threshold: threshold, directory: info.IsDir()}, nil
+}
+
+func (c *FileChecker) Breached(password string) (bool, error) {
+	count, err := c.Count(password)
+	if err != nil {
+		return false, err
+	}
+	return count >= c.threshold, nil
+}
+
+// Count returns how many times the password has been seen in breaches
+func (c *FileChecker) Count(password string) (int, error) {
+	hash := Hash(password)
+	if c.directory {
+		return c.countInRangeFile(hash)
+	}
+	return c.countInSortedFile(hash)
+}
+
+func (c *FileChecker) countInRangeFile(hash string) (int, error) {
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(c.path, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = os.Open(filepath.Join(c.path, prefix))
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, count, ok := parseLine(scanner.Text())
+		if ok && strings.EqualFold(key, suffix) {
+			return count, nil
+		}
+	}
+	return 0, scanner.Err()
+}
+
+func (c *FileChecker) countInSortedFile(hash string) (int, error) {
+	file, err := os.Open(c.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// lo is always the start of a line whose predecessors sort before hash; hi only
+	// bounds the bisection, the scan below runs on until it passes hash
+	lo, hi := int64(0), info.Size()
+	for hi-lo > scanWindow {
+		mid := lo + (hi-lo)/2
+		start, key, err := lineAfter(file, mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			// No line starts in the upper half, only the scan can tell
+			hi = mid
+		} else if key == "" || key >= hash {
+			hi = start
+		} else {
+			lo = start
+		}
+	}
+
+	if _, err := file.Seek(lo, io.SeekStart); err != nil {
+		return 0, err
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, count, ok := parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		switch key = strings.ToUpper(key); {
+		case key == hash:
+			return count, nil
+		case key > hash:
+			return 0, nil
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// lineAfter returns the offset and hash of the first line starting after offset,
+// or an empty hash when there is none
+func lineAfter(file *os.File, offset int64) (int64, string, error) {
+	start := offset
+	buf := make([]byte, 256)
+	for {
+		n, err := file.ReadAt(buf, start)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, "", err
+		}
+		if newline := bytes.IndexByte(buf[:n], '\n'); newline >= 0 {
+			start += int64(newline) + 1
+			break
+		}
+		start += int64(n)
+		if n < len(buf) {
+			return start, "", nil
+		}
+	}
+
+	n, err := file.ReadAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, "", err
+	}
+	line, _, _ := bytes.Cut(buf[:n], []byte("\n"))
+	key, _, _ := parseLine(string(line))
+	return start, strings.ToUpper(key), nil
+}
+
+// ReadHashes calls fn for every hash in the HIBP data at path, a sorted file or a range directory
+func ReadHashes(path string, fn func(hash [20]byte, count int) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return readHashFile(path, "", fn)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		prefix := strings.TrimSuffix(entry.Name(), ".txt")
+		if entry.IsDir() || len(prefix) != 5 {
+			continue
+		}
+		if err := readHashFile(filepath.Join(path, entry.Name()), prefix, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readHashFile(path, prefix string, fn func(hash [20]byte, count int) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		key, count, ok := parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		var hash [20]byte
+		if len(prefix+key) != hex.EncodedLen(len(hash)) {
+			return fmt.Errorf("%s:%d: invalid SHA-1 hash", path, line)
+		}
+		if _, err := hex.Decode(hash[:], []byte(prefix+key)); err != nil {
+			return fmt.Errorf("%s:%d: invalid SHA-1 hash", path, line)
+		}
+		if err := fn(hash, count); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseLine splits a "HASH:COUNT" line; the count defaults to 1 when it is missing
+func parseLine(line string) (string, int, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", 0, false
+	}
+
+	key, countText, found := strings.Cut(line, ":")
+	if !found {
+		return key, 1, true
+	}
+	count, err := strconv.Atoi(countText)
+	if err != nil {
+		return "", 0, false
+	}
+	return key, count, true
+}
@@ -0,0 +1,20 @@
+// Package pwned checks passwords offline against the Have I Been Pwned corpus,
+// either from the downloaded SHA-1 files or from a Bloom filter built from them
+package pwned
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+)
+
+// Checker reports whether a password appears in a breach corpus often enough to be refused
+type Checker interface {
+	Breached(password string) (bool, error)
+}
+
+// Hash returns the SHA-1 of a password as upper-case hex, the form HIBP files use
+func Hash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
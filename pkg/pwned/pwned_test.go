@@ -0,0 +1,228 @@
+package pwned
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// breached maps the passwords of the test corpus to how often they were seen
+var breached = map[string]int{
+	"password":  3861493,
+	"123456":    37359195,
+	"hunter2":   17043,
+	"rarely":    1,
+	"letmein!1": 42,
+}
+
+// notBreached are passwords absent from the test corpus
+var notBreached = []string{"correct horse battery staple", "Tr0ub4dor&3 but longer", ""}
+
+// writeSortedFile writes the corpus, padded with filler hashes so the bisection has work to do,
+// as one file of "HASH:COUNT" lines sorted by hash
+func writeSortedFile(t *testing.T) string {
+	t.Helper()
+
+	var lines []string
+	for password, count := range breached {
+		lines = append(lines, fmt.Sprintf("%s:%d", Hash(password), count))
+	}
+	for i := 0; i < 5000; i++ {
+		lines = append(lines, fmt.Sprintf("%s:%d", Hash(fmt.Sprintf("filler %d", i)), i%7+1))
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600); err != nil {
+		t.Fatalf("write corpus: %v", err)
+	}
+	return path
+}
+
+// writeRangeDirectory writes the corpus as range files named by the hash prefix
+func writeRangeDirectory(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	ranges := map[string][]string{}
+	for password, count := range breached {
+		hash := Hash(password)
+		ranges[hash[:5]] = append(ranges[hash[:5]], fmt.Sprintf("%s:%d", hash[5:], count))
+	}
+	for prefix, lines := range ranges {
+		if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+			t.Fatalf("write range file: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestFileChecker(t *testing.T) {
+	layouts := map[string]func(t *testing.T) string{
+		"sorted file":     writeSortedFile,
+		"range directory": writeRangeDirectory,
+	}
+
+	for name, write := range layouts {
+		t.Run(name, func(t *testing.T) {
+			path := write(t)
+			checker, err := NewFileChecker(path, 1)
+			if err != nil {
+				t.Fatalf("NewFileChecker: %v", err)
+			}
+
+			for password, want := range breached {
+				count, err := checker.Count(password)
+				if err != nil || count != want {
+					t.Errorf("Count(%q) = %d, %v, want %d", password, count, err, want)
+				}
+				if ok, err := checker.Breached(password); err != nil || !ok {
+					t.Errorf("Breached(%q) = %v, %v, want true", password, ok, err)
+				}
+			}
+
+			for _, password := range notBreached {
+				ok, err := checker.Breached(password)
+				// A range directory has no file for most prefixes, which must not read as a hit
+				if ok {
+					t.Errorf("Breached(%q) = true, want false", password)
+				}
+				if err != nil && !os.IsNotExist(err) {
+					t.Errorf("Breached(%q): %v", password, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFileCheckerThreshold(t *testing.T) {
+	checker, err := NewFileChecker(writeSortedFile(t), 100)
+	if err != nil {
+		t.Fatalf("NewFileChecker: %v", err)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"hunter2", true},
+		{"letmein!1", false},
+		{"rarely", false},
+	}
+	for _, tt := range tests {
+		if ok, err := checker.Breached(tt.password); err != nil || ok != tt.want {
+			t.Errorf("Breached(%q) with threshold 100 = %v, %v, want %v", tt.password, ok, err, tt.want)
+		}
+	}
+}
+
+func TestNewFileCheckerMissingPath(t *testing.T) {
+	if _, err := NewFileChecker(filepath.Join(t.TempDir(), "missing.txt"), 1); err == nil {
+		t.Error("NewFileChecker accepted a missing path")
+	}
+}
+
+func TestReadHashes(t *testing.T) {
+	for name, path := range map[string]string{"sorted file": writeSortedFile(t), "range directory": writeRangeDirectory(t)} {
+		seen := map[[20]byte]int{}
+		err := ReadHashes(path, func(hash [20]byte, count int) error {
+			seen[hash] = count
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: ReadHashes: %v", name, err)
+		}
+		for password, want := range breached {
+			if got := seen[sha1.Sum([]byte(password))]; got != want {
+				t.Errorf("%s: count of %q = %d, want %d", name, password, got, want)
+			}
+		}
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	filter, err := NewBloomFilter(uint64(len(breached)), 0.001)
+	if err != nil {
+		t.Fatalf("NewBloomFilter: %v", err)
+	}
+	for password := range breached {
+		filter.Add(sha1.Sum([]byte(password)))
+	}
+
+	// Round trip through the file format before checking
+	var buf bytes.Buffer
+	if _, err := filter.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	loaded, err := ReadBloomFilter(&buf)
+	if err != nil {
+		t.Fatalf("ReadBloomFilter: %v", err)
+	}
+
+	for password := range breached {
+		if ok, err := loaded.Breached(password); err != nil || !ok {
+			t.Errorf("Breached(%q) = %v, %v, want true", password, ok, err)
+		}
+	}
+	for _, password := range notBreached {
+		if ok, _ := loaded.Breached(password); ok {
+			t.Errorf("Breached(%q) = true, want false", password)
+		}
+	}
+}
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	const n = 10000
+	filter, err := NewBloomFilter(n, 0.01)
+	if err != nil {
+		t.Fatalf("NewBloomFilter: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		filter.Add(sha1.Sum([]byte(fmt.Sprintf("breached %d", i))))
+	}
+
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if ok, _ := filter.Breached(fmt.Sprintf("fresh %d", i)); ok {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / n; rate > 0.02 {
+		t.Errorf("false positive rate = %.3f, want about 0.01", rate)
+	}
+}
+
+func TestReadBloomFilterRejectsInvalidInput(t *testing.T) {
+	valid := func() []byte {
+		filter, _ := NewBloomFilter(10, 0.01)
+		var buf bytes.Buffer
+		filter.WriteTo(&buf)
+		return buf.Bytes()
+	}
+
+	tests := map[string][]byte{
+		"empty":          nil,
+		"wrong magic":    append([]byte("NOTBLOOM"), valid()[8:]...),
+		"zero size":      append(append([]byte{}, valid()[:8]...), make([]byte, 12)...),
+		"truncated bits": valid()[:len(valid())-1],
+	}
+	for name, data := range tests {
+		if _, err := ReadBloomFilter(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: ReadBloomFilter accepted invalid input", name)
+		}
+	}
+}
+
+func TestNewBloomFilterRejectsInvalidRate(t *testing.T) {
+	for _, rate := range []float64{0, 1, -0.5, 2} {
+		if _, err := NewBloomFilter(10, rate); err == nil {
+			t.Errorf("NewBloomFilter accepted false positive rate %v", rate)
+		}
+	}
+}
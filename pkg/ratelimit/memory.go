@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rule    Rule
+}
+
+// MemoryStore keeps token buckets in process memory, so limits apply per instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates a new, empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Take(key string, rule Rule) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Capacity), updated: now}
+		s.buckets[key] = b
+	}
+	b.rule = rule
+
+	// Refill for the time that has passed since the last take
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(rule.Capacity), b.tokens+elapsed*rule.RefillRate())
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	return result(rule, b.tokens, allowed), nil
+}
+
+// sweep drops buckets that have refilled completely, at most once a minute
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		elapsed := now.Sub(b.updated).Seconds()
+		if b.tokens+elapsed*b.rule.RefillRate() >= float64(b.rule.Capacity) {
+			delete(s.buckets, key)
+		}
+	}
+}
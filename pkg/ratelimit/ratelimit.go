@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule configures a token bucket: it holds up to Capacity tokens and refills
+// Capacity tokens every Period
+type Rule struct {
+	Capacity int
+	Period   time.Duration
+}
+
+// RefillRate returns the number of tokens added per second
+func (r Rule) RefillRate() float64 {
+	return float64(r.Capacity) / r.Period.Seconds()
+}
+
+// Result describes the state of a bucket after taking a token
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// RetryAfter is how long until the next token is available
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps token buckets
+type Store interface {
+	// Take removes one token from the bucket identified by key
+	Take(key string, rule Rule) (Result, error)
+}
+
+// ParseRule parses a rule written as "<capacity>/<period>", e.g. "10/1m"
+func ParseRule(value string) (Rule, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), "/", 2)
+	if len(parts) != 2 {
+		return Rule{}, fmt.Errorf("invalid rate limit %q, expected <capacity>/<period>", value)
+	}
+
+	capacity, err := strconv.Atoi(parts[0])
+	if err != nil || capacity < 1 {
+		return Rule{}, fmt.Errorf("invalid rate limit capacity in %q", value)
+	}
+
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return Rule{}, fmt.Errorf("invalid rate limit period in %q", value)
+	}
+
+	return Rule{Capacity: capacity, Period: period}, nil
+}
+
+// result builds a Result from the tokens left after a take
+func result(rule Rule, tokens float64, allowed bool) Result {
+	rate := rule.RefillRate()
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     rule.Capacity,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(rule.Capacity) - tokens) / rate * float64(time.Second)),
+	}
+	if tokens < 1 {
+		res.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return res
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Rule
+		wantErr bool
+	}{
+		{"10/1m", Rule{Capacity: 10, Period: time.Minute}, false},
+		{" 5/30s ", Rule{Capacity: 5, Period: 30 * time.Second}, false},
+		{"10", Rule{}, true},
+		{"x/1m", Rule{}, true},
+		{"0/1m", Rule{}, true},
+		{"10/forever", Rule{}, true},
+		{"10/0s", Rule{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRule(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRule(%q) = %+v, %v, want %+v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// storeFactory creates an empty store and a function that lets its buckets refill for d
+type storeFactory func(t *testing.T) (store Store, advance func(d time.Duration))
+
+// testStore runs the cases every Store backend must pass
+func testStore(t *testing.T, newStore storeFactory) {
+	t.Run("bucket empties and refills", func(t *testing.T) {
+		store, advance := newStore(t)
+		rule := Rule{Capacity: 3, Period: 300 * time.Millisecond}
+
+		for i := 2; i >= 0; i-- {
+			result, err := store.Take("key", rule)
+			if err != nil {
+				t.Fatalf("Take: %v", err)
+			}
+			if !result.Allowed || result.Remaining != i || result.Limit != 3 {
+				t.Fatalf("take %d = %+v, want allowed with %d remaining", 3-i, result, i)
+			}
+		}
+
+		result, err := store.Take("key", rule)
+		if err != nil {
+			t.Fatalf("Take: %v", err)
+		}
+		if result.Allowed || result.Remaining != 0 {
+			t.Fatalf("take from an empty bucket = %+v, want denied", result)
+		}
+		// One token comes back every 100ms, the whole bucket in 300ms
+		if result.RetryAfter <= 0 || result.RetryAfter > 100*time.Millisecond {
+			t.Errorf("RetryAfter = %v, want at most 100ms", result.RetryAfter)
+		}
+		if result.Reset < 200*time.Millisecond || result.Reset > 300*time.Millisecond {
+			t.Errorf("Reset = %v, want close to 300ms", result.Reset)
+		}
+
+		advance(150 * time.Millisecond)
+		if result, err := store.Take("key", rule); err != nil || !result.Allowed {
+			t.Errorf("take after a token refilled = %+v, %v, want allowed", result, err)
+		}
+	})
+
+	t.Run("buckets are independent", func(t *testing.T) {
+		store, _ := newStore(t)
+		rule := Rule{Capacity: 1, Period: time.Hour}
+
+		if result, err := store.Take("first", rule); err != nil || !result.Allowed {
+			t.Fatalf("Take = %+v, %v, want allowed", result, err)
+		}
+		if result, err := store.Take("first", rule); err != nil || result.Allowed {
+			t.Fatalf("second take = %+v, %v, want denied", result, err)
+		}
+		if result, err := store.Take("second", rule); err != nil || !result.Allowed {
+			t.Errorf("take from another bucket = %+v, %v, want allowed", result, err)
+		}
+	})
+
+	t.Run("refill never exceeds capacity", func(t *testing.T) {
+		store, advance := newStore(t)
+		rule := Rule{Capacity: 2, Period: 100 * time.Millisecond}
+
+		if _, err := store.Take("key", rule); err != nil {
+			t.Fatalf("Take: %v", err)
+		}
+		advance(500 * time.Millisecond)
+		result, err := store.Take("key", rule)
+		if err != nil {
+			t.Fatalf("Take: %v", err)
+		}
+		if result.Remaining != 1 {
+			t.Errorf("Remaining = %d after a long pause, want capacity minus one", result.Remaining)
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) (Store, func(time.Duration)) {
+		return NewMemoryStore(), time.Sleep
+	})
+}
+
+func TestRedisStore(t *testing.T) {
+	testStore(t, func(t *testing.T) (Store, func(time.Duration)) {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+
+		// The script reads the server clock, which miniredis takes from the host unless told otherwise
+		return NewRedisStore(client, "test:"), func(d time.Duration) {
+			time.Sleep(d)
+			server.FastForward(d)
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository creates a new instance of AuditRepository
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Create stores an audit event
+func (r *AuditRepository) Create(event *models.AuditEvent) error {
+	return r.db.Create(event).Error
+}
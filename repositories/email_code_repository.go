@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EmailCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailCodeRepository creates a new instance of EmailCodeRepository
+func NewEmailCodeRepository(db *gorm.DB) *EmailCodeRepository {
+	return &EmailCodeRepository{db: db}
+}
+
+// Replace stores a new code and invalidates every outstanding code of the same purpose
+func (r *EmailCodeRepository) Replace(code *models.EmailCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.EmailCode{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", code.UserID, code.Purpose).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(code).Error
+	})
+}
+
+// Latest returns the most recently sent code of a purpose, used or not
+func (r *EmailCodeRepository) Latest(userID uint, purpose string) (*models.EmailCode, error) {
+	var code models.EmailCode
+	result := r.db.Where("user_id = ? AND purpose = ?", userID, purpose).Order("created_at DESC").First(&code)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("email code not found")
+		}
+		return nil, result.Error
+	}
+	return &code, nil
+}
+
+// FindActive returns the outstanding, unexpired code of a purpose
+func (r *EmailCodeRepository) FindActive(userID uint, purpose string) (*models.EmailCode, error) {
+	var code models.EmailCode
+	result := r.db.Where("user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", userID, purpose, time.Now()).
+		Order("created_at DESC").
+		First(&code)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("email code not found")
+		}
+		return nil, result.Error
+	}
+	return &code, nil
+}
+
+// RecordAttempt counts a verification attempt, returning false once maxAttempts have been used.
+// Counting before the comparison keeps parallel guesses within the limit.
+func (r *EmailCodeRepository) RecordAttempt(id string, maxAttempts int) (bool, error) {
+	result := r.db.Model(&models.EmailCode{}).
+		Where("id = ? AND used_at IS NULL AND attempts < ?", id, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkUsed consumes a code, returning false if it was already used
+func (r *EmailCodeRepository) MarkUsed(id string) (bool, error) {
+	result := r.db.Model(&models.EmailCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ExternalIdentityRepository struct {
+	db *gorm.DB
+}
+
+// NewExternalIdentityRepository creates a new instance of ExternalIdentityRepository
+func NewExternalIdentityRepository(db *gorm.DB) *ExternalIdentityRepository {
+	return &ExternalIdentityRepository{db: db}
+}
+
+// Create links a new upstream identity to a user
+func (r *ExternalIdentityRepository) Create(identity *models.ExternalIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// FindBySubject retrieves the identity of an upstream account
+func (r *ExternalIdentityRepository) FindBySubject(provider, subject string) (*models.ExternalIdentity, error) {
+	var identity models.ExternalIdentity
+	result := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("external identity not found")
+		}
+		return nil, result.Error
+	}
+	return &identity, nil
+}
+
+// ListByUser returns every upstream identity linked to a user
+func (r *ExternalIdentityRepository) ListByUser(userID uint) ([]models.ExternalIdentity, error) {
+	var identities []models.ExternalIdentity
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error
+	return identities, err
+}
+
+// TouchLogin records a login through an identity and the email the provider reported
+func (r *ExternalIdentityRepository) TouchLogin(id uint, email string) error {
+	return r.db.Model(&models.ExternalIdentity{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"email": email, "last_login_at": time.Now()}).Error
+}
+
+// Delete unlinks one of a user's identities
+func (r *ExternalIdentityRepository) Delete(userID, id uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.ExternalIdentity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("external identity not found")
+	}
+	return nil
+}
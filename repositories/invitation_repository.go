@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type InvitationRepository struct {
+	db *gorm.DB
+}
+
+// NewInvitationRepository creates a new instance of InvitationRepository
+func NewInvitationRepository(db *gorm.DB) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+// Create stores a new invitation
+func (r *InvitationRepository) Create(invitation *models.Invitation) error {
+	return r.db.Create(invitation).Error
+}
+
+// List returns invitations newest first, only those that can still be used if pending is set
+func (r *InvitationRepository) List(pending bool) ([]models.Invitation, error) {
+	query := r.db.Order("created_at DESC")
+	if pending {
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	var invitations []models.Invitation
+	err := query.Find(&invitations).Error
+	return invitations, err
+}
+
+// Revoke stops an unused invitation from being accepted
+func (r *InvitationRepository) Revoke(invitationID string) error {
+	result := r.db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitationID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("invitation not found or no longer pending")
+	}
+	return nil
+}
+
+// Accept marks a pending invitation as accepted and returns it.
+// The conditional update guarantees an invitation can only be accepted once.
+func (r *InvitationRepository) Accept(invitationID string) (*models.Invitation, error) {
+	now := time.Now()
+	result := r.db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", invitationID, now).
+		Update("accepted_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("invitation is invalid, expired or has already been used")
+	}
+
+	var invitation models.Invitation
+	if err := r.db.Where("id = ?", invitationID).First(&invitation).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// Release makes an accepted invitation usable again when the registration it was accepted for failed
+func (r *InvitationRepository) Release(invitationID string) error {
+	return r.db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_by_id IS NULL", invitationID).
+		Update("accepted_at", nil).Error
+}
+
+// SetAcceptedBy records the user who registered with the invitation
+func (r *InvitationRepository) SetAcceptedBy(invitationID string, userID uint) error {
+	return r.db.Model(&models.Invitation{}).
+		Where("id = ?", invitationID).
+		Update("accepted_by_id", userID).Error
+}
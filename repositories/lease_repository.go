@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type LeaseRepository struct {
+	db *gorm.DB
+}
+
+// NewLeaseRepository creates a new instance of LeaseRepository
+func NewLeaseRepository(db *gorm.DB) *LeaseRepository {
+	return &LeaseRepository{db: db}
+}
+
+// TryAcquire takes or renews the named lease for holder.
+// It returns false if another holder owns a lease that has not expired yet.
+func (r *LeaseRepository) TryAcquire(name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	// Renew our own lease or take over an expired one
+	result := r.db.Model(&models.Lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": now.Add(ttl)})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// The lease has never been taken
+	err := r.db.Create(&models.Lease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)}).Error
+	if err == nil {
+		return true, nil
+	}
+
+	// Another replica created it first
+	var count int64
+	if countErr := r.db.Model(&models.Lease{}).Where("name = ?", name).Count(&count).Error; countErr == nil && count > 0 {
+		return false, nil
+	}
+	return false, err
+}
+
+// Release gives up the named lease if holder still owns it
+func (r *LeaseRepository) Release(name, holder string) error {
+	return r.db.Where("name = ? AND holder = ?", name, holder).Delete(&models.Lease{}).Error
+}
@@ -0,0 +1,174 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryTokenStore keeps revocations and sessions in process memory.
+// It suits single-instance deployments and development; data is lost on restart.
+type MemoryTokenStore struct {
+	mu          sync.RWMutex
+	blacklisted map[string]time.Time
+	sessions    map[string]models.Session
+}
+
+// NewMemoryTokenStore creates a new, empty MemoryTokenStore
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		blacklisted: make(map[string]time.Time),
+		sessions:    make(map[string]models.Session),
+	}
+}
+
+func (s *MemoryTokenStore) BlacklistToken(token string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blacklisted[hashToken(token)] = expiresAt
+	return nil
+}
+
+func (s *MemoryTokenStore) ConsumeToken(token string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := hashToken(token)
+	if previous, ok := s.blacklisted[key]; (ok && previous.After(now)) || !expiresAt.After(now) {
+		return ErrTokenConsumed
+	}
+	s.blacklisted[key] = expiresAt
+	return nil
+}
+
+func (s *MemoryTokenStore) IsTokenBlacklisted(token string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expiresAt, ok := s.blacklisted[hashToken(token)]
+	return ok && expiresAt.After(time.Now())
+}
+
+func (s *MemoryTokenStore) DeleteExpiredTokens(batchSize int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int64
+	now := time.Now()
+	for key, expiresAt := range s.blacklisted {
+		if removed >= int64(batchSize) {
+			break
+		}
+		if expiresAt.Before(now) {
+			delete(s.blacklisted, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *MemoryTokenStore) CreateSession(session *models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessions[session.ID]; exists {
+		return errors.New("session already exists")
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	s.sessions[session.ID] = *session
+	return nil
+}
+
+func (s *MemoryTokenStore) GetSession(sessionID string) (*models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, errors.New("session not found")
+	}
+	return &session, nil
+}
+
+func (s *MemoryTokenStore) IsSessionActive(sessionID string) bool {
+	session, err := s.GetSession(sessionID)
+	return err == nil && session.IsActive()
+}
+
+func (s *MemoryTokenStore) TouchSession(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[sessionID]; ok {
+		session.LastUsedAt = time.Now()
+		s.sessions[sessionID] = session
+	}
+	return nil
+}
+
+func (s *MemoryTokenStore) RevokeSession(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[sessionID]; ok && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+		s.sessions[sessionID] = session
+	}
+	return nil
+}
+
+func (s *MemoryTokenStore) RevokeUserSessions(userID uint, exceptSessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, session := range s.sessions {
+		if session.UserID == userID && id != exceptSessionID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			s.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTokenStore) ListActiveSessions(userID uint) ([]models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sessions []models.Session
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.IsActive() {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (s *MemoryTokenStore) DeleteExpiredSessions(batchSize int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int64
+	now := time.Now()
+	for id, session := range s.sessions {
+		if removed >= int64(batchSize) {
+			break
+		}
+		if session.ExpiresAt.Before(now) {
+			delete(s.sessions, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
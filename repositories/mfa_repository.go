@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MFARepository struct {
+	db *gorm.DB
+}
+
+// NewMFARepository creates a new instance of MFARepository
+func NewMFARepository(db *gorm.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// SetPendingTOTPSecret stores a TOTP secret that still has to be confirmed
+func (r *MFARepository) SetPendingTOTPSecret(userID uint, secret string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ? AND mfa_enabled = ?", userID, false).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error
+}
+
+// UpdateTOTPSecret replaces the stored secret of an enrolled user, e.g. after re-encrypting it
+func (r *MFARepository) UpdateTOTPSecret(userID uint, secret string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("totp_secret", secret).Error
+}
+
+// EnableMFA turns on MFA and replaces the user's recovery codes in one transaction
+func (r *MFARepository) EnableMFA(userID uint, step int64, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]interface{}{"mfa_enabled": true, "totp_last_step": step}).Error
+		if err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// DisableMFA turns off MFA and removes the secret and recovery codes
+func (r *MFARepository) DisableMFA(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]interface{}{"mfa_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// UseTOTPStep records a used time step. It returns false if that step or a later one
+// was already used, which rejects replayed codes.
+func (r *MFARepository) UseTOTPStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReplaceRecoveryCodes swaps the user's recovery codes for a new set
+func (r *MFARepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used, returning false if there is none
+func (r *MFARepository) ConsumeRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes the user has left
+func (r *MFARepository) CountUnusedRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]models.RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
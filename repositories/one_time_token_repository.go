@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OneTimeTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewOneTimeTokenRepository creates a new instance of OneTimeTokenRepository
+func NewOneTimeTokenRepository(db *gorm.DB) *OneTimeTokenRepository {
+	return &OneTimeTokenRepository{db: db}
+}
+
+// Create stores a new one-time token
+func (r *OneTimeTokenRepository) Create(token *models.OneTimeToken) error {
+	return r.db.Create(token).Error
+}
+
+// Consume marks an unused, unexpired token with a matching binding as used and returns it.
+// The conditional update guarantees a token can only be consumed once.
+func (r *OneTimeTokenRepository) Consume(tokenID, purpose, binding string) (*models.OneTimeToken, error) {
+	now := time.Now()
+	result := r.db.Model(&models.OneTimeToken{}).
+		Where("id = ? AND purpose = ? AND binding = ? AND used_at IS NULL AND expires_at > ?", tokenID, purpose, binding, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("token is invalid, expired or has already been used")
+	}
+
+	var token models.OneTimeToken
+	if err := r.db.Where("id = ?", tokenID).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// InvalidateForUser marks every outstanding token of a purpose as used, e.g. when a new one is issued
+func (r *OneTimeTokenRepository) InvalidateForUser(userID uint, purpose string) error {
+	return r.db.Model(&models.OneTimeToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordHistoryRepository creates a new instance of PasswordHistoryRepository
+func NewPasswordHistoryRepository(db *gorm.DB) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{db: db}
+}
+
+// Add records a password hash the user no longer uses
+func (r *PasswordHistoryRepository) Add(userID uint, hash string) error {
+	return r.db.Create(&models.PasswordHistory{UserID: userID, Hash: hash}).Error
+}
+
+// Recent returns the user's last limit password hashes, newest first
+func (r *PasswordHistoryRepository) Recent(userID uint, limit int) ([]string, error) {
+	var hashes []string
+	err := r.db.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Pluck("hash", &hashes).Error
+	return hashes, err
+}
+
+// Prune deletes all but the user's last keep password hashes
+func (r *PasswordHistoryRepository) Prune(userID uint, keep int) error {
+	var keepIDs []uint
+	err := r.db.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(keep).
+		Pluck("id", &keepIDs).Error
+	if err != nil {
+		return err
+	}
+
+	query := r.db.Where("user_id = ?", userID)
+	if len(keepIDs) > 0 {
+		query = query.Where("id NOT IN ?", keepIDs)
+	}
+	return query.Delete(&models.PasswordHistory{}).Error
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PersonalAccessTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewPersonalAccessTokenRepository creates a new instance of PersonalAccessTokenRepository
+func NewPersonalAccessTokenRepository(db *gorm.DB) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{db: db}
+}
+
+// Create stores the metadata of a new personal access token
+func (r *PersonalAccessTokenRepository) Create(token *models.PersonalAccessToken) error {
+	return r.db.Create(token).Error
+}
+
+// FindByID retrieves a personal access token by its ID
+func (r *PersonalAccessTokenRepository) FindByID(tokenID string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	result := r.db.Where("id = ?", tokenID).First(&token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("personal access token not found")
+		}
+		return nil, result.Error
+	}
+	return &token, nil
+}
+
+// ListByUser returns every personal access token a user has created, newest first
+func (r *PersonalAccessTokenRepository) ListByUser(userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// Revoke marks a user's personal access token as revoked
+func (r *PersonalAccessTokenRepository) Revoke(userID uint, tokenID string) error {
+	result := r.db.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("personal access token not found")
+	}
+	return nil
+}
+
+// RevokeAllForUser revokes every active personal access token of a user
+func (r *PersonalAccessTokenRepository) RevokeAllForUser(userID uint) error {
+	return r.db.Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// TouchLastUsed records the last time a personal access token was used
+func (r *PersonalAccessTokenRepository) TouchLastUsed(tokenID string) error {
+	return r.db.Model(&models.PersonalAccessToken{}).
+		Where("id = ?", tokenID).
+		Update("last_used_at", time.Now()).Error
+}
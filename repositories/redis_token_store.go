@@ -0,0 +1,233 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisTokenStore keeps revocations and sessions in any server speaking the Redis protocol.
+// Entries carry native TTLs, so expired data disappears without a janitor.
+type RedisTokenStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// hsetIfExists updates a session field without resurrecting a session that has already expired
+var hsetIfExists = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+end
+return 0
+`)
+
+// hsetnxIfExists sets a session field only once, e.g. so a revocation time is never overwritten
+var hsetnxIfExists = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('HSETNX', KEYS[1], ARGV[1], ARGV[2])
+end
+return 0
+`)
+
+// NewRedisTokenStore creates a RedisTokenStore using keys under the given prefix.
+// Any client works, including one pointed at an embedded stand-in server.
+func NewRedisTokenStore(client redis.UniversalClient, prefix string) *RedisTokenStore {
+	return &RedisTokenStore{client: client, prefix: prefix}
+}
+
+func (s *RedisTokenStore) BlacklistToken(token string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// Already expired, nothing to revoke
+		return nil
+	}
+	return s.client.Set(context.Background(), s.revokedKey(token), 1, ttl).Err()
+}
+
+func (s *RedisTokenStore) ConsumeToken(token string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return ErrTokenConsumed
+	}
+	added, err := s.client.SetNX(context.Background(), s.revokedKey(token), 1, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !added {
+		return ErrTokenConsumed
+	}
+	return nil
+}
+
+func (s *RedisTokenStore) IsTokenBlacklisted(token string) bool {
+	exists, err := s.client.Exists(context.Background(), s.revokedKey(token)).Result()
+	return err == nil && exists > 0
+}
+
+// DeleteExpiredTokens is a no-op, Redis expires revocations itself
+func (s *RedisTokenStore) DeleteExpiredTokens(batchSize int) (int64, error) {
+	return 0, nil
+}
+
+func (s *RedisTokenStore) CreateSession(session *models.Session) error {
+	ctx := context.Background()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+
+	key := s.sessionKey(session.ID)
+	userKey := s.userSessionsKey(session.UserID)
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, map[string]interface{}{
+			"user_id":      session.UserID,
+			"remember_me":  strconv.FormatBool(session.RememberMe),
+			"user_agent":   session.UserAgent,
+			"ip_address":   session.IPAddress,
+			"expires_at":   formatTime(session.ExpiresAt),
+			"last_used_at": formatTime(session.LastUsedAt),
+			"created_at":   formatTime(session.CreatedAt),
+		})
+		pipe.ExpireAt(ctx, key, session.ExpiresAt)
+		pipe.SAdd(ctx, userKey, session.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keep the user's session index alive as long as their longest session
+	ttl, err := s.client.TTL(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+	if ttl < time.Until(session.ExpiresAt) {
+		return s.client.ExpireAt(ctx, userKey, session.ExpiresAt).Err()
+	}
+	return nil
+}
+
+func (s *RedisTokenStore) GetSession(sessionID string) (*models.Session, error) {
+	fields, err := s.client.HGetAll(context.Background(), s.sessionKey(sessionID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("session not found")
+	}
+	return parseSession(sessionID, fields)
+}
+
+func (s *RedisTokenStore) IsSessionActive(sessionID string) bool {
+	session, err := s.GetSession(sessionID)
+	return err == nil && session.IsActive()
+}
+
+func (s *RedisTokenStore) TouchSession(sessionID string) error {
+	return hsetIfExists.Run(context.Background(), s.client,
+		[]string{s.sessionKey(sessionID)}, "last_used_at", formatTime(time.Now())).Err()
+}
+
+func (s *RedisTokenStore) RevokeSession(sessionID string) error {
+	return hsetnxIfExists.Run(context.Background(), s.client,
+		[]string{s.sessionKey(sessionID)}, "revoked_at", formatTime(time.Now())).Err()
+}
+
+func (s *RedisTokenStore) RevokeUserSessions(userID uint, exceptSessionID string) error {
+	sessionIDs, err := s.client.SMembers(context.Background(), s.userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		if sessionID == exceptSessionID {
+			continue
+		}
+		if err := s.RevokeSession(sessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RedisTokenStore) ListActiveSessions(userID uint) ([]models.Session, error) {
+	ctx := context.Background()
+	userKey := s.userSessionsKey(userID)
+
+	sessionIDs, err := s.client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []models.Session
+	for _, sessionID := range sessionIDs {
+		session, err := s.GetSession(sessionID)
+		if err != nil {
+			// The session key has expired, drop it from the index
+			s.client.SRem(ctx, userKey, sessionID)
+			continue
+		}
+		if session.IsActive() {
+			sessions = append(sessions, *session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// DeleteExpiredSessions is a no-op, Redis expires sessions itself
+func (s *RedisTokenStore) DeleteExpiredSessions(batchSize int) (int64, error) {
+	return 0, nil
+}
+
+func (s *RedisTokenStore) revokedKey(token string) string {
+	return s.prefix + "revoked:" + hashToken(token)
+}
+
+func (s *RedisTokenStore) sessionKey(sessionID string) string {
+	return s.prefix + "session:" + sessionID
+}
+
+func (s *RedisTokenStore) userSessionsKey(userID uint) string {
+	return s.prefix + "user_sessions:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func parseSession(sessionID string, fields map[string]string) (*models.Session, error) {
+	userID, err := strconv.ParseUint(fields["user_id"], 10, 64)
+	if err != nil {
+		return nil, errors.New("corrupt session")
+	}
+
+	session := &models.Session{
+		ID:         sessionID,
+		UserID:     uint(userID),
+		RememberMe: fields["remember_me"] == "true",
+		UserAgent:  fields["user_agent"],
+		IPAddress:  fields["ip_address"],
+		ExpiresAt:  parseTime(fields["expires_at"]),
+		LastUsedAt: parseTime(fields["last_used_at"]),
+		CreatedAt:  parseTime(fields["created_at"]),
+	}
+	if revokedAt, ok := fields["revoked_at"]; ok {
+		t := parseTime(revokedAt)
+		session.RevokedAt = &t
+	}
+	return session, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, value)
+	return t
+}
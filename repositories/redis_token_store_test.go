@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisTokenStore(t *testing.T, prefix string) (*RedisTokenStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewRedisTokenStore(client, prefix), server
+}
+
+func TestRedisTokenStore(t *testing.T) {
+	testTokenStore(t, func(t *testing.T) (TokenStore, func(time.Duration)) {
+		store, server := newTestRedisTokenStore(t, "test:")
+		// miniredis only expires keys when told that time has passed, the stores compare against the clock
+		return store, func(d time.Duration) {
+			time.Sleep(d)
+			server.FastForward(d)
+		}
+	})
+}
+
+func TestRedisTokenStoreUsesNativeTTLs(t *testing.T) {
+	store, server := newTestRedisTokenStore(t, "test:")
+
+	if err := store.BlacklistToken("token", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("BlacklistToken: %v", err)
+	}
+	if err := store.CreateSession(newTestSession("session", 1, 2*time.Hour)); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	revokedKey := store.revokedKey("token")
+	if ttl := server.TTL(revokedKey); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("revocation TTL = %v, want at most an hour", ttl)
+	}
+	if ttl := server.TTL(store.sessionKey("session")); ttl <= time.Hour || ttl > 2*time.Hour {
+		t.Errorf("session TTL = %v, want between one and two hours", ttl)
+	}
+	if ttl := server.TTL(store.userSessionsKey(1)); ttl <= time.Hour {
+		t.Errorf("session index TTL = %v, want it to outlive the session", ttl)
+	}
+
+	// Raw tokens never reach the server
+	for _, key := range server.Keys() {
+		if key == "test:revoked:token" {
+			t.Error("revocation key contains the raw token")
+		}
+	}
+}
+
+func TestRedisTokenStoreDropsExpiredSessionsFromIndex(t *testing.T) {
+	store, server := newTestRedisTokenStore(t, "test:")
+
+	if err := store.CreateSession(newTestSession("short", 1, time.Minute)); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := store.CreateSession(newTestSession("long", 1, time.Hour)); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	server.FastForward(2 * time.Minute)
+	sessions, err := store.ListActiveSessions(1)
+	if err != nil {
+		t.Fatalf("ListActiveSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "long" {
+		t.Errorf("ListActiveSessions returned %v, want only the long session", sessions)
+	}
+
+	members, err := server.Members(store.userSessionsKey(1))
+	if err != nil {
+		t.Fatalf("Members: %v", err)
+	}
+	if len(members) != 1 || members[0] != "long" {
+		t.Errorf("session index = %v, want [long]", members)
+	}
+}
@@ -1,18 +1,16 @@
 package repositories
 
 import (
+	"JwtSecurityImplementation/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// BlacklistedToken represents a blacklisted JWT token in the database
-type BlacklistedToken struct {
-	gorm.Model
-	Token     string    `gorm:"uniqueIndex;not null"`
-	ExpiresAt time.Time `gorm:"not null"`
-}
-
 type TokenRepository struct {
 	db *gorm.DB
 }
@@ -22,36 +20,170 @@ func NewTokenRepository(db *gorm.DB) *TokenRepository {
 	return &TokenRepository{db: db}
 }
 
-// BlacklistToken adds a token to the blacklist
+// BlacklistToken adds a token to the blacklist.
+// Expired entries are removed by the RevocationJanitor, not here.
 func (r *TokenRepository) BlacklistToken(token string, expiresAt time.Time) error {
-	// Remove any expired blacklisted tokens first
-	r.removeExpiredTokens()
-
 	// Create new blacklisted token entry
-	blacklistedToken := BlacklistedToken{
-		Token:     token,
+	blacklistedToken := models.BlacklistedToken{
+		Token:     hashToken(token),
 		ExpiresAt: expiresAt,
 	}
 
-	return r.db.Create(&blacklistedToken).Error
+	// Blacklisting the same token twice is not an error
+	return r.db.Where(models.BlacklistedToken{Token: blacklistedToken.Token}).
+		FirstOrCreate(&blacklistedToken).Error
+}
+
+// ConsumeToken blacklists a single-use token. The insert skips an existing entry,
+// so only the caller that actually added it succeeds.
+func (r *TokenRepository) ConsumeToken(token string, expiresAt time.Time) error {
+	if !expiresAt.After(time.Now()) {
+		return ErrTokenConsumed
+	}
+
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.BlacklistedToken{
+		Token:     hashToken(token),
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTokenConsumed
+	}
+	return nil
 }
 
 // IsTokenBlacklisted checks if a token has been blacklisted
 func (r *TokenRepository) IsTokenBlacklisted(token string) bool {
 	var count int64
-	result := r.db.Model(&BlacklistedToken{}).
-		Where("token = ? AND expires_at > ?", token, time.Now()).
+	result := r.db.Model(&models.BlacklistedToken{}).
+		Where("token = ? AND expires_at > ?", hashToken(token), time.Now()).
 		Count(&count)
 
 	return result.Error == nil && count > 0
 }
 
-// removeExpiredTokens cleans up expired blacklisted tokens
-func (r *TokenRepository) removeExpiredTokens() {
-	r.db.Where("expires_at < ?", time.Now()).Delete(&BlacklistedToken{})
-}
-
 // CleanupBlacklistedTokens provides a method to manually trigger token cleanup
 func (r *TokenRepository) CleanupBlacklistedTokens() error {
-	return r.db.Where("expires_at < ?", time.Now()).Delete(&BlacklistedToken{}).Error
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&models.BlacklistedToken{}).Error
+}
+
+// DeleteExpiredTokens removes up to batchSize expired blacklist entries and returns how many were deleted
+func (r *TokenRepository) DeleteExpiredTokens(batchSize int) (int64, error) {
+	var tokens []string
+	err := r.db.Model(&models.BlacklistedToken{}).
+		Where("expires_at < ?", time.Now()).
+		Limit(batchSize).
+		Pluck("token", &tokens).Error
+	if err != nil || len(tokens) == 0 {
+		return 0, err
+	}
+
+	result := r.db.Where("token IN ?", tokens).Delete(&models.BlacklistedToken{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteExpiredSessions removes up to batchSize expired sessions and returns how many were deleted
+func (r *TokenRepository) DeleteExpiredSessions(batchSize int) (int64, error) {
+	var sessionIDs []string
+	err := r.db.Model(&models.Session{}).
+		Where("expires_at < ?", time.Now()).
+		Limit(batchSize).
+		Pluck("id", &sessionIDs).Error
+	if err != nil || len(sessionIDs) == 0 {
+		return 0, err
+	}
+
+	result := r.db.Where("id IN ?", sessionIDs).Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
+
+// CreateSession stores a new refresh token session
+func (r *TokenRepository) CreateSession(session *models.Session) error {
+	return r.db.Create(session).Error
+}
+
+// GetSession retrieves a session by its ID
+func (r *TokenRepository) GetSession(sessionID string) (*models.Session, error) {
+	var session models.Session
+	result := r.db.Where("id = ?", sessionID).First(&session)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("session not found")
+		}
+		return nil, result.Error
+	}
+	return &session, nil
+}
+
+// IsSessionActive checks if a session exists, has not expired and has not been revoked
+func (r *TokenRepository) IsSessionActive(sessionID string) bool {
+	session, err := r.GetSession(sessionID)
+	return err == nil && session.IsActive()
+}
+
+// TouchSession records the last time a session was used
+func (r *TokenRepository) TouchSession(sessionID string) error {
+	return r.db.Model(&models.Session{}).
+		Where("id = ?", sessionID).
+		Update("last_used_at", time.Now()).Error
+}
+
+// RevokeSession marks a session as revoked
+func (r *TokenRepository) RevokeSession(sessionID string) error {
+	return r.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserSessions revokes all of a user's sessions except exceptSessionID, which may be empty
+func (r *TokenRepository) RevokeUserSessions(userID uint, exceptSessionID string) error {
+	return r.db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptSessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// ListActiveSessions returns a user's sessions that have neither expired nor been revoked
+func (r *TokenRepository) ListActiveSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// legacyBlacklistTable held raw tokens before they were stored hashed
+const legacyBlacklistTable = "blacklisted_tokens"
+
+// MigrateLegacyBlacklist hashes the unexpired entries of the legacy blacklist table into the
+// current one and drops it, so tokens revoked before the upgrade stay revoked. Changing the key
+// column in place is not possible, it is the primary key and raw tokens exceed its new length.
+func MigrateLegacyBlacklist(db *gorm.DB) error {
+	if !db.Migrator().HasTable(legacyBlacklistTable) {
+		return nil
+	}
+
+	var legacy []models.BlacklistedToken
+	err := db.Table(legacyBlacklistTable).
+		Where("expires_at > ?", time.Now()).
+		Find(&legacy).Error
+	if err != nil {
+		return err
+	}
+
+	repo := NewTokenRepository(db)
+	for _, entry := range legacy {
+		if err := repo.BlacklistToken(entry.Token, entry.ExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	return db.Migrator().DropTable(legacyBlacklistTable)
+}
+
+// hashToken derives the key a token is stored under, so raw tokens never reach the database
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"errors"
+	"time"
+)
+
+// ErrTokenConsumed is returned by ConsumeToken when the token has already been used or has expired
+var ErrTokenConsumed = errors.New("token has already been used")
+
+// TokenStore persists token revocations and refresh token sessions.
+// TokenRepository (SQL), MemoryTokenStore and RedisTokenStore implement it.
+type TokenStore interface {
+	// BlacklistToken revokes a token until expiresAt
+	BlacklistToken(token string, expiresAt time.Time) error
+	// IsTokenBlacklisted checks if a token has been revoked
+	IsTokenBlacklisted(token string) bool
+	// ConsumeToken revokes a single-use token and fails with ErrTokenConsumed if it already was,
+	// so of several concurrent callers only one can spend it
+	ConsumeToken(token string, expiresAt time.Time) error
+	// DeleteExpiredTokens removes up to batchSize expired revocations
+	DeleteExpiredTokens(batchSize int) (int64, error)
+
+	// CreateSession stores a new refresh token session
+	CreateSession(session *models.Session) error
+	// GetSession retrieves a session by its ID
+	GetSession(sessionID string) (*models.Session, error)
+	// IsSessionActive checks if a session exists, has not expired and has not been revoked
+	IsSessionActive(sessionID string) bool
+	// TouchSession records the last time a session was used
+	TouchSession(sessionID string) error
+	// RevokeSession marks a session as revoked
+	RevokeSession(sessionID string) error
+	// RevokeUserSessions revokes all of a user's sessions except exceptSessionID, which may be empty
+	RevokeUserSessions(userID uint, exceptSessionID string) error
+	// ListActiveSessions returns a user's sessions that have neither expired nor been revoked
+	ListActiveSessions(userID uint) ([]models.Session, error)
+	// DeleteExpiredSessions removes up to batchSize expired sessions
+	DeleteExpiredSessions(batchSize int) (int64, error)
+}
+
+var (
+	_ TokenStore = (*TokenRepository)(nil)
+	_ TokenStore = (*MemoryTokenStore)(nil)
+	_ TokenStore = (*RedisTokenStore)(nil)
+)
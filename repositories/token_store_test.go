@@ -0,0 +1,253 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// tokenStoreFactory creates an empty store and a function that lets its entries age by d
+type tokenStoreFactory func(t *testing.T) (store TokenStore, advance func(d time.Duration))
+
+// testTokenStore runs the cases every TokenStore backend must pass
+func testTokenStore(t *testing.T, newStore tokenStoreFactory) {
+	t.Run("blacklist expires with the token", func(t *testing.T) {
+		store, advance := newStore(t)
+
+		if err := store.BlacklistToken("short-lived", time.Now().Add(50*time.Millisecond)); err != nil {
+			t.Fatalf("BlacklistToken: %v", err)
+		}
+		if err := store.BlacklistToken("long-lived", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("BlacklistToken: %v", err)
+		}
+		if !store.IsTokenBlacklisted("short-lived") || !store.IsTokenBlacklisted("long-lived") {
+			t.Fatal("freshly blacklisted tokens are not reported as blacklisted")
+		}
+		if store.IsTokenBlacklisted("never-blacklisted") {
+			t.Fatal("unknown token reported as blacklisted")
+		}
+
+		advance(100 * time.Millisecond)
+		if store.IsTokenBlacklisted("short-lived") {
+			t.Error("blacklist entry outlived its token")
+		}
+		if !store.IsTokenBlacklisted("long-lived") {
+			t.Error("unexpired blacklist entry disappeared")
+		}
+	})
+
+	t.Run("blacklisting an expired token is a no-op", func(t *testing.T) {
+		store, _ := newStore(t)
+
+		if err := store.BlacklistToken("expired", time.Now().Add(-time.Minute)); err != nil {
+			t.Fatalf("BlacklistToken: %v", err)
+		}
+		if store.IsTokenBlacklisted("expired") {
+			t.Error("expired token reported as blacklisted")
+		}
+	})
+
+	t.Run("consume a token once", func(t *testing.T) {
+		store, _ := newStore(t)
+
+		const attempts = 8
+		errs := make(chan error, attempts)
+		var wg sync.WaitGroup
+		for i := 0; i < attempts; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- store.ConsumeToken("single-use", time.Now().Add(time.Hour))
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		consumed := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				consumed++
+			case !errors.Is(err, ErrTokenConsumed):
+				t.Errorf("ConsumeToken: %v", err)
+			}
+		}
+		if consumed != 1 {
+			t.Fatalf("token consumed %d times concurrently, want once", consumed)
+		}
+		if !store.IsTokenBlacklisted("single-use") {
+			t.Error("consumed token is not blacklisted")
+		}
+
+		if err := store.BlacklistToken("revoked", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("BlacklistToken: %v", err)
+		}
+		if err := store.ConsumeToken("revoked", time.Now().Add(time.Hour)); !errors.Is(err, ErrTokenConsumed) {
+			t.Errorf("ConsumeToken of a blacklisted token returned %v, want ErrTokenConsumed", err)
+		}
+		if err := store.ConsumeToken("expired", time.Now().Add(-time.Minute)); !errors.Is(err, ErrTokenConsumed) {
+			t.Errorf("ConsumeToken of an expired token returned %v, want ErrTokenConsumed", err)
+		}
+	})
+
+	t.Run("session create and revoke", func(t *testing.T) {
+		store, _ := newStore(t)
+
+		session := newTestSession("session-1", 1, time.Hour)
+		if err := store.CreateSession(session); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		if !store.IsSessionActive("session-1") {
+			t.Fatal("new session is not active")
+		}
+
+		stored, err := store.GetSession("session-1")
+		if err != nil {
+			t.Fatalf("GetSession: %v", err)
+		}
+		if stored.UserID != 1 || stored.UserAgent != session.UserAgent || !stored.RememberMe {
+			t.Errorf("GetSession returned %+v, want the created session", stored)
+		}
+
+		if err := store.RevokeSession("session-1"); err != nil {
+			t.Fatalf("RevokeSession: %v", err)
+		}
+		if store.IsSessionActive("session-1") {
+			t.Error("revoked session is still active")
+		}
+		if store.IsSessionActive("unknown") {
+			t.Error("unknown session reported as active")
+		}
+	})
+
+	t.Run("session expires", func(t *testing.T) {
+		store, advance := newStore(t)
+
+		if err := store.CreateSession(newTestSession("short", 1, 50*time.Millisecond)); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+
+		advance(100 * time.Millisecond)
+		if store.IsSessionActive("short") {
+			t.Error("expired session is still active")
+		}
+		sessions, err := store.ListActiveSessions(1)
+		if err != nil {
+			t.Fatalf("ListActiveSessions: %v", err)
+		}
+		if len(sessions) != 0 {
+			t.Errorf("ListActiveSessions returned %d expired sessions", len(sessions))
+		}
+	})
+
+	t.Run("revoke all sessions except the current one", func(t *testing.T) {
+		store, _ := newStore(t)
+
+		for _, session := range []*models.Session{
+			newTestSession("current", 1, time.Hour),
+			newTestSession("other-1", 1, time.Hour),
+			newTestSession("other-2", 1, time.Hour),
+			newTestSession("someone-else", 2, time.Hour),
+		} {
+			if err := store.CreateSession(session); err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+		}
+
+		if err := store.RevokeUserSessions(1, "current"); err != nil {
+			t.Fatalf("RevokeUserSessions: %v", err)
+		}
+		for id, active := range map[string]bool{"current": true, "other-1": false, "other-2": false, "someone-else": true} {
+			if store.IsSessionActive(id) != active {
+				t.Errorf("session %s active = %v, want %v", id, !active, active)
+			}
+		}
+
+		if err := store.RevokeUserSessions(1, ""); err != nil {
+			t.Fatalf("RevokeUserSessions: %v", err)
+		}
+		if store.IsSessionActive("current") {
+			t.Error("RevokeUserSessions without exception left a session active")
+		}
+	})
+
+	t.Run("list active sessions most recently used first", func(t *testing.T) {
+		store, _ := newStore(t)
+
+		for _, session := range []*models.Session{
+			newTestSession("first", 1, time.Hour),
+			newTestSession("second", 1, time.Hour),
+			newTestSession("revoked", 1, time.Hour),
+			newTestSession("someone-else", 2, time.Hour),
+		} {
+			if err := store.CreateSession(session); err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+		}
+		if err := store.RevokeSession("revoked"); err != nil {
+			t.Fatalf("RevokeSession: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		if err := store.TouchSession("first"); err != nil {
+			t.Fatalf("TouchSession: %v", err)
+		}
+
+		sessions, err := store.ListActiveSessions(1)
+		if err != nil {
+			t.Fatalf("ListActiveSessions: %v", err)
+		}
+		var ids []string
+		for _, session := range sessions {
+			ids = append(ids, session.ID)
+		}
+		if len(ids) != 2 || ids[0] != "first" || ids[1] != "second" {
+			t.Errorf("ListActiveSessions returned %v, want [first second]", ids)
+		}
+	})
+}
+
+func newTestSession(id string, userID uint, ttl time.Duration) *models.Session {
+	now := time.Now()
+	return &models.Session{
+		ID:         id,
+		UserID:     userID,
+		RememberMe: true,
+		UserAgent:  "test-agent",
+		IPAddress:  "192.0.2.1",
+		ExpiresAt:  now.Add(ttl),
+		LastUsedAt: now,
+	}
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	testTokenStore(t, func(t *testing.T) (TokenStore, func(time.Duration)) {
+		return NewMemoryTokenStore(), time.Sleep
+	})
+}
+
+func TestTokenRepository(t *testing.T) {
+	testTokenStore(t, func(t *testing.T) (TokenStore, func(time.Duration)) {
+		db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+		if err != nil {
+			t.Fatalf("open database: %v", err)
+		}
+		if err := db.AutoMigrate(&models.BlacklistedToken{}, &models.Session{}); err != nil {
+			t.Fatalf("migrate database: %v", err)
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			t.Fatalf("database handle: %v", err)
+		}
+		t.Cleanup(func() { sqlDB.Close() })
+
+		return NewTokenRepository(db), time.Sleep
+	})
+}
@@ -3,10 +3,26 @@ package repositories
 import (
 	"JwtSecurityImplementation/models"
 	"errors"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// UserFilter narrows ListUsers; nil fields match every user
+type UserFilter struct {
+	// Search matches part of the email address, first or last name
+	Search     string
+	Role       string
+	Verified   *bool
+	Status     string
+	Locked     *bool
+	MFAEnabled *bool
+	Offset     int
+	Limit      int
+}
+
 type UserRepository struct {
 	db *gorm.DB
 }
@@ -23,6 +39,13 @@ func (r *UserRepository) CreateUser(user *models.User) (*models.User, error) {
 		return nil, errors.New("email already exists")
 	}
 
+	if user.Status == "" {
+		user.Status = models.UserStatusActive
+		if user.EmailVerifiedAt == nil {
+			user.Status = models.UserStatusPendingVerification
+		}
+	}
+
 	// Create new user
 	if err := r.db.Create(user).Error; err != nil {
 		return nil, err
@@ -57,3 +80,238 @@ func (r *UserRepository) GetUserByID(userID uint) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+// MarkEmailVerified records that the user has confirmed their email address
+func (r *UserRepository) MarkEmailVerified(userID uint) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"email_verified_at": time.Now(),
+			"status":            statusUnless(models.UserStatusActive, models.UserStatusSuspended, models.UserStatusLocked),
+			"status_changed_at": time.Now(),
+		}).Error
+}
+
+// UpdatePassword replaces the user's password hash
+func (r *UserRepository) UpdatePassword(userID uint, hashedPassword string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{"password": hashedPassword, "password_changed_at": time.Now(), "password_reset_required": false}).Error
+}
+
+// UpdatePasswordHash stores a new hash of the same password, e.g. after a rehash, keeping its age
+func (r *UserRepository) UpdatePasswordHash(userID uint, hashedPassword string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("password", hashedPassword).Error
+}
+
+// UpdateProfile changes the user's first and last name
+func (r *UserRepository) UpdateProfile(userID uint, firstName, lastName string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{"first_name": firstName, "last_name": lastName}).Error
+}
+
+// IncrementFailedLogins atomically counts a failed login and returns the new count
+func (r *UserRepository) IncrementFailedLogins(userID uint) (int, error) {
+	err := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("failed_login_attempts", gorm.Expr("failed_login_attempts + 1")).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var attempts int
+	err = r.db.Model(&models.User{}).Where("id = ?", userID).Pluck("failed_login_attempts", &attempts).Error
+	return attempts, err
+}
+
+// LockUser locks the account until the given time and counts the lockout
+func (r *UserRepository) LockUser(userID uint, until time.Time) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"locked_until":          until,
+			"failed_login_attempts": 0,
+			"lockout_count":         gorm.Expr("lockout_count + 1"),
+			"status":                statusUnless(models.UserStatusLocked, models.UserStatusSuspended),
+			"status_reason":         gorm.Expr("CASE WHEN status = ? THEN status_reason ELSE ? END", models.UserStatusSuspended, "too many failed login attempts"),
+			"status_changed_at":     time.Now(),
+		}).Error
+}
+
+// ResetLoginFailures clears the failed attempt and lockout counters after a successful login
+func (r *UserRepository) ResetLoginFailures(userID uint) error {
+	err := r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"failed_login_attempts": 0,
+			"lockout_count":         0,
+			"locked_until":          nil,
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	return r.db.Model(&models.User{}).
+		Where("id = ? AND status = ?", userID, models.UserStatusLocked).
+		Updates(map[string]interface{}{"status": unlockedStatus(), "status_reason": "", "status_changed_at": time.Now()}).Error
+}
+
+// GrantRole adds a role to the user with the given email if they do not have it yet
+func (r *UserRepository) GrantRole(email, role string) error {
+	user, err := r.FindUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user.HasRole(role) {
+		return nil
+	}
+
+	user.Roles = append(user.Roles, role)
+	return r.db.Model(user).Select("roles").Updates(user).Error
+}
+
+// SetRoles replaces every role of the user
+func (r *UserRepository) SetRoles(userID uint, roles []string) error {
+	user := models.User{Roles: roles}
+	user.ID = userID
+	return r.db.Model(&user).Select("roles").Updates(&user).Error
+}
+
+// ListUsers returns one page of the users matching the filter, ordered by ID, and the total number of matches
+func (r *UserRepository) ListUsers(filter UserFilter) ([]models.User, int64, error) {
+	query := r.db.Model(&models.User{})
+
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		query = query.Where("(email LIKE ? ESCAPE '\\' OR first_name LIKE ? ESCAPE '\\' OR last_name LIKE ? ESCAPE '\\')", pattern, pattern, pattern)
+	}
+	if filter.Role != "" {
+		// Roles are stored as a JSON array of strings
+		query = query.Where("roles LIKE ? ESCAPE '\\'", "%\""+escapeLike(filter.Role)+"\"%")
+	}
+	if filter.Verified != nil {
+		if *filter.Verified {
+			query = query.Where("email_verified_at IS NOT NULL")
+		} else {
+			query = query.Where("email_verified_at IS NULL")
+		}
+	}
+	if filter.Locked != nil {
+		if *filter.Locked {
+			query = query.Where("locked_until > ?", time.Now())
+		} else {
+			query = query.Where("(locked_until IS NULL OR locked_until <= ?)", time.Now())
+		}
+	}
+	// Lockouts expire without the stored status changing, so every state but a suspension
+	// is derived from the lockout and verification columns
+	switch filter.Status {
+	case "":
+	case models.UserStatusLocked:
+		query = query.Where("status <> ? AND locked_until > ?", models.UserStatusSuspended, time.Now())
+	case models.UserStatusActive, models.UserStatusPendingVerification:
+		query = query.Where("status <> ? AND (locked_until IS NULL OR locked_until <= ?)", models.UserStatusSuspended, time.Now())
+		if filter.Status == models.UserStatusActive {
+			query = query.Where("email_verified_at IS NOT NULL")
+		} else {
+			query = query.Where("email_verified_at IS NULL")
+		}
+	default:
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.MFAEnabled != nil {
+		query = query.Where("mfa_enabled = ?", *filter.MFAEnabled)
+	}
+
+	// The count and the page share the conditions built so far
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	err := query.Order("id").Offset(filter.Offset).Limit(filter.Limit).Find(&users).Error
+	return users, total, err
+}
+
+// Suspend stops the user from signing in until Reactivate is called
+func (r *UserRepository) Suspend(userID uint, reason string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"status":            models.UserStatusSuspended,
+			"status_reason":     reason,
+			"status_changed_at": time.Now(),
+		}).Error
+}
+
+// Reactivate lifts a suspension, returning the user to the state their lockout and verification imply
+func (r *UserRepository) Reactivate(userID uint) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ? AND status = ?", userID, models.UserStatusSuspended).
+		Updates(map[string]interface{}{
+			"status":            gorm.Expr("CASE WHEN locked_until > ? THEN ? ELSE ? END", time.Now(), models.UserStatusLocked, unlockedStatus()),
+			"status_reason":     "",
+			"status_changed_at": time.Now(),
+		}).Error
+}
+
+// RequirePasswordReset stops password logins for the user until the password is changed
+func (r *UserRepository) RequirePasswordReset(userID uint) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("password_reset_required", true).Error
+}
+
+// MigrateUserStatus moves accounts disabled before statuses existed to the suspended state and
+// drops the disabled_at and disabled_reason columns. Users who had not verified their address when
+// the status column was added start out active, so they are moved to pending_verification.
+func MigrateUserStatus(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if migrator.HasColumn(&models.User{}, "disabled_at") {
+		err := db.Unscoped().Model(&models.User{}).
+			Where("disabled_at IS NOT NULL").
+			Updates(map[string]interface{}{
+				"status":            models.UserStatusSuspended,
+				"status_reason":     gorm.Expr("COALESCE(disabled_reason, '')"),
+				"status_changed_at": gorm.Expr("disabled_at"),
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, column := range []string{"disabled_at", "disabled_reason"} {
+			if !migrator.HasColumn(&models.User{}, column) {
+				continue
+			}
+			if err := migrator.DropColumn(&models.User{}, column); err != nil {
+				return err
+			}
+		}
+	}
+
+	return db.Unscoped().Model(&models.User{}).
+		Where("status = ? AND email_verified_at IS NULL", models.UserStatusActive).
+		Update("status", models.UserStatusPendingVerification).Error
+}
+
+// statusUnless sets status, unless the user is in one of the states that take precedence.
+// Like every SET expression it sees the row as it was before the update.
+func statusUnless(status string, precedence ...string) clause.Expr {
+	return gorm.Expr("CASE WHEN status IN ? THEN status ELSE ? END", precedence, status)
+}
+
+// unlockedStatus is the state of a user who is neither suspended nor locked
+func unlockedStatus() clause.Expr {
+	return gorm.Expr("CASE WHEN email_verified_at IS NULL THEN ? ELSE ? END", models.UserStatusPendingVerification, models.UserStatusActive)
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, to be used with ESCAPE '\'
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_", "[", "\\[").Replace(value)
+}
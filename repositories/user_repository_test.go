@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestUserDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("database handle: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db
+}
+
+// insertTestUser stores the user as given, bypassing the defaults of CreateUser
+func insertTestUser(t *testing.T, db *gorm.DB, email, status string, verified bool, lockedUntil *time.Time) uint {
+	t.Helper()
+
+	user := &models.User{FirstName: "Test", Email: email, Status: status, LockedUntil: lockedUntil}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create %s: %v", email, err)
+	}
+	return user.ID
+}
+
+func TestMigrateUserStatus(t *testing.T) {
+	db := newTestUserDB(t)
+
+	// The columns accounts were disabled with before statuses existed
+	for _, statement := range []string{
+		"ALTER TABLE users ADD COLUMN `disabled_at` datetime",
+		"ALTER TABLE users ADD COLUMN `disabled_reason` varchar(255)",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	disabled := insertTestUser(t, db, "disabled@example.com", models.UserStatusActive, true, nil)
+	unverified := insertTestUser(t, db, "unverified@example.com", models.UserStatusActive, false, nil)
+	verified := insertTestUser(t, db, "verified@example.com", models.UserStatusActive, true, nil)
+	disabledAt := time.Now().Add(-time.Hour)
+	err := db.Exec("UPDATE users SET disabled_at = ?, disabled_reason = ? WHERE id = ?", disabledAt, "left the company", disabled).Error
+	if err != nil {
+		t.Fatalf("disable user: %v", err)
+	}
+
+	if err := MigrateUserStatus(db); err != nil {
+		t.Fatalf("MigrateUserStatus: %v", err)
+	}
+	// Running it again at the next start changes nothing
+	if err := MigrateUserStatus(db); err != nil {
+		t.Fatalf("second MigrateUserStatus: %v", err)
+	}
+
+	repo := NewUserRepository(db)
+	for id, want := range map[uint]string{
+		disabled:   models.UserStatusSuspended,
+		unverified: models.UserStatusPendingVerification,
+		verified:   models.UserStatusActive,
+	} {
+		user, err := repo.GetUserByID(id)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if user.Status != want {
+			t.Errorf("%s has status %q, want %q", user.Email, user.Status, want)
+		}
+	}
+
+	user, err := repo.GetUserByID(disabled)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if user.StatusReason != "left the company" || user.StatusChangedAt == nil {
+		t.Errorf("suspended user = reason %q changed at %v, want the disable reason and time", user.StatusReason, user.StatusChangedAt)
+	}
+
+	for _, column := range []string{"disabled_at", "disabled_reason"} {
+		if db.Migrator().HasColumn(&models.User{}, column) {
+			t.Errorf("column %s was not dropped", column)
+		}
+	}
+}
+
+func TestListUsersDerivesLockoutStatus(t *testing.T) {
+	db := newTestUserDB(t)
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	insertTestUser(t, db, "active@example.com", models.UserStatusActive, true, nil)
+	insertTestUser(t, db, "pending@example.com", models.UserStatusPendingVerification, false, nil)
+	insertTestUser(t, db, "locked@example.com", models.UserStatusLocked, true, &future)
+	insertTestUser(t, db, "expired@example.com", models.UserStatusLocked, true, &past)
+	insertTestUser(t, db, "expired-pending@example.com", models.UserStatusLocked, false, &past)
+	insertTestUser(t, db, "suspended@example.com", models.UserStatusSuspended, true, &future)
+
+	repo := NewUserRepository(db)
+	for status, want := range map[string][]string{
+		models.UserStatusActive:              {"active@example.com", "expired@example.com"},
+		models.UserStatusPendingVerification: {"expired-pending@example.com", "pending@example.com"},
+		models.UserStatusLocked:              {"locked@example.com"},
+		models.UserStatusSuspended:           {"suspended@example.com"},
+	} {
+		users, total, err := repo.ListUsers(UserFilter{Status: status, Limit: 100})
+		if err != nil {
+			t.Fatalf("ListUsers(%s): %v", status, err)
+		}
+
+		var emails []string
+		for _, user := range users {
+			emails = append(emails, user.Email)
+			// The listed status agrees with the filter, even where the stored one is stale
+			if user.Status != status {
+				t.Errorf("%s listed as %q under the %q filter", user.Email, user.Status, status)
+			}
+		}
+		sort.Strings(emails)
+		if int(total) != len(want) || len(emails) != len(want) {
+			t.Errorf("ListUsers(%s) = %v (total %d), want %v", status, emails, total, want)
+			continue
+		}
+		for i := range want {
+			if emails[i] != want[i] {
+				t.Errorf("ListUsers(%s) = %v, want %v", status, emails, want)
+				break
+			}
+		}
+	}
+}
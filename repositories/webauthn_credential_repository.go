@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"JwtSecurityImplementation/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WebAuthnCredentialRepository struct {
+	db *gorm.DB
+}
+
+// NewWebAuthnCredentialRepository creates a new instance of WebAuthnCredentialRepository
+func NewWebAuthnCredentialRepository(db *gorm.DB) *WebAuthnCredentialRepository {
+	return &WebAuthnCredentialRepository{db: db}
+}
+
+// Create stores a newly registered credential
+func (r *WebAuthnCredentialRepository) Create(credential *models.WebAuthnCredential) error {
+	return r.db.Create(credential).Error
+}
+
+// ListByUser returns every credential a user has registered, oldest first
+func (r *WebAuthnCredentialRepository) ListByUser(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&credentials).Error
+	return credentials, err
+}
+
+// FindByCredentialID retrieves a credential by its base64url encoded credential ID
+func (r *WebAuthnCredentialRepository) FindByCredentialID(credentialID string) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	result := r.db.Where("credential_id = ?", credentialID).First(&credential)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("credential not found")
+		}
+		return nil, result.Error
+	}
+	return &credential, nil
+}
+
+// RecordUse stores the state reported by the authenticator after a successful assertion
+func (r *WebAuthnCredentialRepository) RecordUse(id uint, signCount uint32, backupState, cloneWarning bool) error {
+	return r.db.Model(&models.WebAuthnCredential{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"sign_count":    signCount,
+			"backup_state":  backupState,
+			"clone_warning": cloneWarning,
+			"last_used_at":  time.Now(),
+		}).Error
+}
+
+// ClearCloneWarning lets a credential sign in again after its clone warning has been investigated.
+// The next assertion must still report a counter above the stored one.
+func (r *WebAuthnCredentialRepository) ClearCloneWarning(userID, id uint) error {
+	result := r.db.Model(&models.WebAuthnCredential{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("clone_warning", false)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("credential not found")
+	}
+	return nil
+}
+
+// Rename changes the display name of a user's credential
+func (r *WebAuthnCredentialRepository) Rename(userID, id uint, name string) error {
+	result := r.db.Model(&models.WebAuthnCredential{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("name", name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("credential not found")
+	}
+	return nil
+}
+
+// Delete removes a user's credential
+func (r *WebAuthnCredentialRepository) Delete(userID, id uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("credential not found")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"JwtSecurityImplementation/controllers"
+	"JwtSecurityImplementation/middleware"
+	"JwtSecurityImplementation/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupAdminRoutes(r *gin.Engine, adminController *controllers.AdminController, jwtMiddleware *middleware.JWTMiddleware) {
+	// Admin routes require an interactive session with the admin role
+	adminGroup := r.Group("/admin")
+	adminGroup.Use(jwtMiddleware.Authenticate(), jwtMiddleware.RequireInteractiveSession(), jwtMiddleware.RequireRole(services.RoleAdmin))
+	{
+		adminGroup.GET("/users", adminController.ListUsers)
+		adminGroup.GET("/users/:id", adminController.GetUser)
+		adminGroup.POST("/users/:id/unlock", adminController.UnlockUser)
+		adminGroup.POST("/users/:id/suspend", adminController.SuspendUser)
+		adminGroup.POST("/users/:id/reactivate", adminController.ReactivateUser)
+		// Names from before account statuses, kept for existing clients
+		adminGroup.POST("/users/:id/disable", adminController.SuspendUser)
+		adminGroup.POST("/users/:id/enable", adminController.ReactivateUser)
+		adminGroup.POST("/users/:id/force-password-reset", adminController.ForcePasswordReset)
+		adminGroup.POST("/users/:id/revoke-sessions", adminController.RevokeSessions)
+		adminGroup.PUT("/users/:id/roles", adminController.SetRoles)
+		adminGroup.POST("/users/:id/passkeys/:passkeyId/clear-clone-warning", adminController.ClearPasskeyCloneWarning)
+		adminGroup.DELETE("/users/:id/passkeys/:passkeyId", adminController.DeletePasskey)
+		adminGroup.GET("/invitations", adminController.ListInvitations)
+		adminGroup.POST("/invitations", adminController.CreateInvitation)
+		adminGroup.DELETE("/invitations/:id", adminController.RevokeInvitation)
+	}
+}
@@ -7,13 +7,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupAuthRoutes(r *gin.Engine, authController *controllers.AuthController, jwtMiddleware *middleware.JWTMiddleware) {
+func SetupAuthRoutes(r *gin.Engine, authController *controllers.AuthController, jwtMiddleware *middleware.JWTMiddleware, rateLimiter *middleware.RateLimiter) {
 	// Public routes
 	authGroup := r.Group("/auth")
 	{
-		authGroup.POST("/register", authController.Register)
-		authGroup.POST("/login", authController.Login)
-		authGroup.POST("/refresh", authController.RefreshToken)
+		authGroup.POST("/register", rateLimiter.Limit("register"), authController.Register)
+		authGroup.POST("/login", rateLimiter.Limit("login"), authController.Login)
+		authGroup.POST("/refresh", rateLimiter.Limit("refresh"), authController.RefreshToken)
+		authGroup.GET("/verify-email", authController.VerifyEmail)
+		authGroup.POST("/unlock", rateLimiter.Limit("unlock"), authController.RequestUnlock)
+		authGroup.GET("/unlock", authController.Unlock)
 	}
 
 	// Protected routes
@@ -21,9 +24,17 @@ func SetupAuthRoutes(r *gin.Engine, authController *controllers.AuthController,
 	protectedGroup.Use(jwtMiddleware.Authenticate())
 	{
 		protectedGroup.POST("/logout", authController.Logout)
+		protectedGroup.POST("/verify-email/resend", authController.ResendVerification)
 		// Add more protected routes as needed
 	}
 
+	// Routes personal access tokens can reach with the given scope
+	scopedGroup := r.Group("/auth")
+	{
+		scopedGroup.GET("/me", jwtMiddleware.Authenticate("read"), authController.Me)
+		scopedGroup.PATCH("/me", jwtMiddleware.Authenticate("write"), authController.UpdateMe)
+	}
+
 	// Optional: Health check route
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"JwtSecurityImplementation/controllers"
+	"JwtSecurityImplementation/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupEmailCodeRoutes(r *gin.Engine, emailCodeController *controllers.EmailCodeController, jwtMiddleware *middleware.JWTMiddleware, rateLimiter *middleware.RateLimiter) {
+	// Public routes
+	emailCodeGroup := r.Group("/auth")
+	{
+		emailCodeGroup.POST("/email-code", rateLimiter.Limit("email_code"), emailCodeController.RequestLoginCode)
+		emailCodeGroup.POST("/email-code/login", rateLimiter.Limit("email_code_login"), emailCodeController.Login)
+		emailCodeGroup.POST("/mfa/email-code", rateLimiter.Limit("email_code"), emailCodeController.SendMFACode)
+	}
+
+	// Step-up verification for the current session
+	stepUpGroup := r.Group("/auth/step-up")
+	stepUpGroup.Use(jwtMiddleware.Authenticate(), jwtMiddleware.RequireInteractiveSession())
+	{
+		stepUpGroup.POST("/email-code", rateLimiter.Limit("email_code"), emailCodeController.SendStepUpCode)
+		stepUpGroup.POST("/verify", rateLimiter.Limit("step_up"), emailCodeController.VerifyStepUp)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"JwtSecurityImplementation/controllers"
+	"JwtSecurityImplementation/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupMagicLinkRoutes(r *gin.Engine, magicLinkController *controllers.MagicLinkController, rateLimiter *middleware.RateLimiter) {
+	// Public routes
+	magicLinkGroup := r.Group("/auth/magic-link")
+	{
+		magicLinkGroup.POST("", rateLimiter.Limit("magic_link"), magicLinkController.Request)
+		magicLinkGroup.GET("", magicLinkController.Confirm)
+		magicLinkGroup.POST("/consume", rateLimiter.Limit("magic_link_consume"), magicLinkController.Consume)
+	}
+}
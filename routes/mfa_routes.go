@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"JwtSecurityImplementation/controllers"
+	"JwtSecurityImplementation/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupMFARoutes(r *gin.Engine, mfaController *controllers.MFAController, jwtMiddleware *middleware.JWTMiddleware, rateLimiter *middleware.RateLimiter) {
+	// Public routes
+	mfaGroup := r.Group("/auth/mfa")
+	{
+		mfaGroup.POST("/verify", rateLimiter.Limit("mfa_verify"), mfaController.Verify)
+	}
+
+	// Protected routes
+	protectedGroup := r.Group("/auth/mfa")
+	protectedGroup.Use(jwtMiddleware.Authenticate(), jwtMiddleware.RequireInteractiveSession(), jwtMiddleware.RequireVerifiedEmail())
+	{
+		protectedGroup.GET("", mfaController.Status)
+		protectedGroup.POST("/totp/enroll", mfaController.EnrollTOTP)
+		protectedGroup.POST("/totp/confirm", mfaController.ConfirmTOTP)
+		protectedGroup.POST("/disable", mfaController.Disable)
+		protectedGroup.POST("/recovery-codes", mfaController.RegenerateRecoveryCodes)
+	}
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"JwtSecurityImplementation/controllers"
+	"JwtSecurityImplementation/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupOIDCRoutes(r *gin.Engine, oidcController *controllers.OIDCController, jwtMiddleware *middleware.JWTMiddleware, rateLimiter *middleware.RateLimiter) {
+	// Public routes
+	oidcGroup := r.Group("/auth/oidc")
+	{
+		oidcGroup.GET("/providers", oidcController.Providers)
+		oidcGroup.GET("/:provider/login", rateLimiter.Limit("oidc_login"), oidcController.Login)
+		oidcGroup.GET("/:provider/callback", rateLimiter.Limit("oidc_login"), oidcController.Callback)
+	}
+
+	// Protected routes
+	protectedGroup := r.Group("/auth/oidc/identities")
+	protectedGroup.Use(jwtMiddleware.Authenticate(), jwtMiddleware.RequireInteractiveSession(), jwtMiddleware.RequireVerifiedEmail())
+	{
+		protectedGroup.GET("", oidcController.ListIdentities)
+		protectedGroup.DELETE("/:id", oidcController.UnlinkIdentity)
+	}
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"JwtSecurityImplementation/controllers"
+	"JwtSecurityImplementation/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupPasskeyRoutes(r *gin.Engine, passkeyController *controllers.PasskeyController, jwtMiddleware *middleware.JWTMiddleware, rateLimiter *middleware.RateLimiter) {
+	// Public routes
+	passkeyGroup := r.Group("/auth/passkeys")
+	{
+		passkeyGroup.POST("/login/begin", rateLimiter.Limit("passkey_login"), passkeyController.BeginLogin)
+		passkeyGroup.POST("/login/finish", rateLimiter.Limit("passkey_login"), passkeyController.FinishLogin)
+	}
+
+	// Protected routes
+	protectedGroup := r.Group("/auth/passkeys")
+	protectedGroup.Use(jwtMiddleware.Authenticate(), jwtMiddleware.RequireInteractiveSession(), jwtMiddleware.RequireVerifiedEmail())
+	{
+		protectedGroup.GET("", passkeyController.ListPasskeys)
+		protectedGroup.POST("/register/begin", jwtMiddleware.RequireStepUp(), passkeyController.BeginRegistration)
+		// The single-use ceremony from /register/begin already proves the step-up
+		protectedGroup.POST("/register/finish", passkeyController.FinishRegistration)
+		protectedGroup.PATCH("/:id", passkeyController.RenamePasskey)
+		protectedGroup.DELETE("/:id", passkeyController.DeletePasskey)
+	}
+}
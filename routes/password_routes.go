@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"JwtSecurityImplementation/controllers"
+	"JwtSecurityImplementation/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupPasswordRoutes(r *gin.Engine, passwordController *controllers.PasswordController, jwtMiddleware *middleware.JWTMiddleware, rateLimiter *middleware.RateLimiter) {
+	// Public routes
+	passwordGroup := r.Group("/auth/password")
+	{
+		passwordGroup.POST("/forgot", rateLimiter.Limit("forgot_password"), passwordController.ForgotPassword)
+		passwordGroup.POST("/reset", rateLimiter.Limit("reset_password"), passwordController.ResetPassword)
+	}
+
+	// Protected routes
+	protectedGroup := r.Group("/auth/password")
+	protectedGroup.Use(jwtMiddleware.Authenticate(), jwtMiddleware.RequireInteractiveSession(), jwtMiddleware.RequireVerifiedEmail())
+	{
+		protectedGroup.POST("/change", passwordController.ChangePassword)
+	}
+}
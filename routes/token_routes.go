@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"JwtSecurityImplementation/controllers"
+	"JwtSecurityImplementation/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupTokenRoutes(r *gin.Engine, tokenController *controllers.TokenController, jwtMiddleware *middleware.JWTMiddleware) {
+	// Credential management requires an interactive session, not a personal access token
+	tokenGroup := r.Group("/auth/tokens")
+	tokenGroup.Use(jwtMiddleware.Authenticate(), jwtMiddleware.RequireInteractiveSession(), jwtMiddleware.RequireVerifiedEmail())
+	{
+		tokenGroup.GET("", tokenController.Inventory)
+		tokenGroup.GET("/personal", tokenController.ListPersonalAccessTokens)
+		tokenGroup.POST("/personal", jwtMiddleware.RequireStepUp(), tokenController.CreatePersonalAccessToken)
+		tokenGroup.DELETE("/personal/:id", tokenController.RevokePersonalAccessToken)
+	}
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+)
+
+// AdminUserDetails is what an administrator sees of a single user
+type AdminUserDetails struct {
+	User     *models.User                `json:"user"`
+	Sessions []models.Session            `json:"sessions"`
+	Passkeys []models.WebAuthnCredential `json:"passkeys"`
+}
+
+// AdminService lets administrators manage user accounts. Every change is written to the audit trail.
+type AdminService struct {
+	userRepo        *repositories.UserRepository
+	credentialRepo  *repositories.WebAuthnCredentialRepository
+	tokenService    *TokenService
+	passwordService *PasswordService
+	auditService    *AuditService
+}
+
+func NewAdminService(userRepo *repositories.UserRepository, credentialRepo *repositories.WebAuthnCredentialRepository, tokenService *TokenService, passwordService *PasswordService, auditService *AuditService) *AdminService {
+	return &AdminService{
+		userRepo:        userRepo,
+		credentialRepo:  credentialRepo,
+		tokenService:    tokenService,
+		passwordService: passwordService,
+		auditService:    auditService,
+	}
+}
+
+// ListUsers returns one page of the users matching the filter and the total number of matches
+func (s *AdminService) ListUsers(filter repositories.UserFilter) ([]models.User, int64, error) {
+	return s.userRepo.ListUsers(filter)
+}
+
+// GetUser returns a user with their active sessions and passkeys
+func (s *AdminService) GetUser(userID uint) (*AdminUserDetails, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	inventory, err := s.tokenService.GetTokenInventory(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys, err := s.credentialRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminUserDetails{User: user, Sessions: inventory.Sessions, Passkeys: passkeys}, nil
+}
+
+// SuspendUser stops a user from signing in or refreshing tokens. Their sessions are revoked,
+// so access tokens already issued are refused on their next use.
+func (s *AdminService) SuspendUser(userID, actorID uint, reason string, client models.ClientInfo) error {
+	if userID == actorID {
+		return errors.New("administrators cannot suspend their own account")
+	}
+	if _, err := s.userRepo.GetUserByID(userID); err != nil {
+		return err
+	}
+
+	reason = strings.TrimSpace(reason)
+	if err := s.userRepo.Suspend(userID, reason); err != nil {
+		return err
+	}
+	if err := s.tokenService.RevokeUserSessions(userID, ""); err != nil {
+		return err
+	}
+
+	s.auditService.Record(models.AuditAccountSuspended, &userID, &actorID, client.IPAddress, map[string]interface{}{
+		"reason": reason,
+	})
+	return nil
+}
+
+// ReactivateUser lifts a suspension
+func (s *AdminService) ReactivateUser(userID, actorID uint, client models.ClientInfo) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsSuspended() {
+		return errors.New("user is not suspended")
+	}
+
+	if err := s.userRepo.Reactivate(userID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(models.AuditAccountReactivated, &userID, &actorID, client.IPAddress, nil)
+	return nil
+}
+
+// ForcePasswordReset refuses every login of the user, with or without a password, until they
+// choose a new one through the emailed reset link, and ends their sessions. The account may be
+// compromised, so personal access tokens are revoked as well; unknown passkeys can be removed
+// with DeletePasskey.
+func (s *AdminService) ForcePasswordReset(userID, actorID uint, client models.ClientInfo) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.RequirePasswordReset(userID); err != nil {
+		return err
+	}
+	if err := s.tokenService.RevokeUserSessions(userID, ""); err != nil {
+		return err
+	}
+	if err := s.tokenService.RevokeAllPersonalAccessTokens(userID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(models.AuditPasswordResetForced, &userID, &actorID, client.IPAddress, nil)
+
+	// The reset is in force either way, the user can request another link
+	if err := s.passwordService.ForgotPassword(user.Email); err != nil {
+		log.Printf("Failed to send password reset email to user %d: %v", userID, err)
+	}
+	return nil
+}
+
+// RevokeSessions signs the user out everywhere
+func (s *AdminService) RevokeSessions(userID, actorID uint, client models.ClientInfo) error {
+	if _, err := s.userRepo.GetUserByID(userID); err != nil {
+		return err
+	}
+
+	if err := s.tokenService.RevokeUserSessions(userID, ""); err != nil {
+		return err
+	}
+
+	s.auditService.Record(models.AuditSessionsRevoked, &userID, &actorID, client.IPAddress, nil)
+	return nil
+}
+
+// ClearPasskeyCloneWarning re-enables a passkey that was refused because its sign counter went backwards,
+// once the administrator is satisfied the authenticator was not cloned
+func (s *AdminService) ClearPasskeyCloneWarning(userID, credentialID, actorID uint, client models.ClientInfo) error {
+	if err := s.credentialRepo.ClearCloneWarning(userID, credentialID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(models.AuditPasskeyCloneCleared, &userID, &actorID, client.IPAddress, map[string]interface{}{
+		"passkey": credentialID,
+	})
+	return nil
+}
+
+// DeletePasskey removes one of the user's passkeys, e.g. one an attacker registered on a compromised account
+func (s *AdminService) DeletePasskey(userID, credentialID, actorID uint, client models.ClientInfo) error {
+	if err := s.credentialRepo.Delete(userID, credentialID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(models.AuditPasskeyDeleted, &userID, &actorID, client.IPAddress, map[string]interface{}{
+		"passkey": credentialID,
+	})
+	return nil
+}
+
+// SetRoles replaces the user's roles. New access tokens carry them from the next refresh on.
+func (s *AdminService) SetRoles(userID, actorID uint, roles []string, client models.ClientInfo) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := normalizeRoles(roles)
+
+	// An administrator removing their own admin role could leave nobody to undo it
+	if userID == actorID && !containsRole(normalized, RoleAdmin) {
+		return nil, errors.New("administrators cannot remove their own admin role")
+	}
+
+	if err := s.userRepo.SetRoles(userID, normalized); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(models.AuditRolesChanged, &userID, &actorID, client.IPAddress, map[string]interface{}{
+		"previous": user.Roles,
+		"roles":    normalized,
+	})
+
+	user.Roles = normalized
+	return user, nil
+}
+
+// normalizeRoles trims the roles and drops empty and duplicate ones
+func normalizeRoles(roles []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, role := range roles {
+		role = strings.TrimSpace(role)
+		if role != "" && !seen[role] {
+			seen[role] = true
+			normalized = append(normalized, role)
+		}
+	}
+	return normalized
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
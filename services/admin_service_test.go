@@ -0,0 +1,43 @@
+package services
+
+import (
+	"testing"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+)
+
+func TestAdminDeletePasskey(t *testing.T) {
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	credentialRepo := repositories.NewWebAuthnCredentialRepository(db)
+	service := NewAdminService(userRepo, credentialRepo, nil, nil, NewAuditService(repositories.NewAuditRepository(db)))
+
+	owner := createTestUser(t, userRepo, &models.User{FirstName: "Jane", Email: "jane@example.com"})
+	other := createTestUser(t, userRepo, &models.User{FirstName: "John", Email: "john@example.com"})
+	credential := &models.WebAuthnCredential{UserID: owner.ID, Name: "Unknown key", CredentialID: "credential-1", PublicKey: []byte{1}}
+	if err := credentialRepo.Create(credential); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := service.DeletePasskey(other.ID, credential.ID, 1, testClient); err == nil {
+		t.Fatal("deleted a passkey through another user")
+	}
+	if err := service.DeletePasskey(owner.ID, credential.ID, 1, testClient); err != nil {
+		t.Fatalf("DeletePasskey: %v", err)
+	}
+
+	passkeys, err := credentialRepo.ListByUser(owner.ID)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	if len(passkeys) != 0 {
+		t.Errorf("%d passkeys left after deletion", len(passkeys))
+	}
+
+	var audited int64
+	db.Model(&models.AuditEvent{}).Where("type = ? AND user_id = ?", models.AuditPasskeyDeleted, owner.ID).Count(&audited)
+	if audited != 1 {
+		t.Errorf("recorded %d passkey deletion audit events, want 1", audited)
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+)
+
+// AuditService writes security events to the audit trail
+type AuditService struct {
+	auditRepo *repositories.AuditRepository
+}
+
+func NewAuditService(auditRepo *repositories.AuditRepository) *AuditService {
+	return &AuditService{auditRepo: auditRepo}
+}
+
+// Record stores an audit event. Events are also written to the log, so one is
+// never lost when the database write fails.
+func (s *AuditService) Record(eventType string, userID, actorID *uint, ipAddress string, details map[string]interface{}) {
+	var encoded string
+	if len(details) > 0 {
+		if data, err := json.Marshal(details); err == nil {
+			encoded = string(data)
+		}
+	}
+
+	log.Printf("Audit: type=%s user=%v actor=%v ip=%s details=%s", eventType, derefID(userID), derefID(actorID), ipAddress, encoded)
+
+	event := &models.AuditEvent{
+		Type:      eventType,
+		UserID:    userID,
+		ActorID:   actorID,
+		IPAddress: ipAddress,
+		Details:   encoded,
+	}
+	if err := s.auditRepo.Create(event); err != nil {
+		log.Printf("Failed to store audit event %s: %v", eventType, err)
+	}
+}
+
+func derefID(id *uint) interface{} {
+	if id == nil {
+		return "-"
+	}
+	return *id
+}
@@ -1,48 +1,218 @@
 package services
 
 import (
+	"JwtSecurityImplementation/internal/config"
 	"JwtSecurityImplementation/models"
 	"JwtSecurityImplementation/repositories"
 	"JwtSecurityImplementation/utils"
 	"errors"
+	"log"
+	"strings"
+	"time"
 )
 
+// ErrEmailTaken is returned by Register when an account already uses the email address
+var ErrEmailTaken = errors.New("email already exists")
+
 type AuthService struct {
-	userRepo *repositories.UserRepository
+	userRepo       *repositories.UserRepository
+	lockoutService *LockoutService
+	passwordPolicy *PasswordPolicy
+	invitations    *InvitationService
+	authenticators []Authenticator
 }
 
-func NewAuthService(userRepo *repositories.UserRepository) *AuthService {
-	return &AuthService{userRepo: userRepo}
+// NewAuthService creates the service; without authenticators only local passwords are checked
+func NewAuthService(userRepo *repositories.UserRepository, lockoutService *LockoutService, passwordPolicy *PasswordPolicy, invitations *InvitationService, authenticators ...Authenticator) *AuthService {
+	if len(authenticators) == 0 {
+		authenticators = []Authenticator{NewLocalAuthenticator(userRepo)}
+	}
+
+	return &AuthService{
+		userRepo:       userRepo,
+		lockoutService: lockoutService,
+		passwordPolicy: passwordPolicy,
+		invitations:    invitations,
+		authenticators: authenticators,
+	}
 }
 
-func (s *AuthService) Register(user *models.User) (*models.User, error) {
+// Register creates a user as REGISTRATION_MODE allows. An invitation code is required in invite mode,
+// admits addresses outside the allowed domains in domain mode, and assigns its roles and tenant.
+func (s *AuthService) Register(user *models.User, invitationCode string, client models.ClientInfo) (*models.User, error) {
 	// Additional validation can be added here
 	if user.Email == "" {
 		return nil, errors.New("email is required")
 	}
 
-	// Hash password
+	mode := RegistrationMode()
+	switch {
+	case mode == RegistrationDisabled:
+		return nil, ErrRegistrationDisabled
+	case invitationCode != "":
+	case mode == RegistrationInvite:
+		return nil, ErrInvitationRequired
+	case mode == RegistrationDomain && !registrationDomainAllowed(user.Email):
+		return nil, ErrEmailDomainNotAllowed
+	}
+
+	if err := s.passwordPolicy.Check(user, user.Password); err != nil {
+		return nil, err
+	}
+
+	// Hash before looking the address up, so taken addresses take as long to answer
 	hashedPassword, err := utils.HashPassword(user.Password)
 	if err != nil {
 		return nil, err
 	}
+
+	var invitation *models.Invitation
+	if invitationCode != "" {
+		if invitation, err = s.invitations.accept(invitationCode, user.Email); err != nil {
+			return nil, err
+		}
+		user.Roles = invitation.Roles
+		user.Tenant = invitation.Tenant
+	}
+
+	if _, err := s.userRepo.FindUserByEmail(user.Email); err == nil {
+		if invitation != nil {
+			s.invitations.release(invitation)
+		}
+		return nil, ErrEmailTaken
+	}
+
+	now := time.Now()
 	user.Password = hashedPassword
+	user.PasswordChangedAt = &now
 
 	// Create user
-	return s.userRepo.CreateUser(user)
+	createdUser, err := s.userRepo.CreateUser(user)
+	if err != nil {
+		if invitation != nil {
+			s.invitations.release(invitation)
+		}
+		return nil, err
+	}
+
+	if invitation != nil {
+		s.invitations.complete(invitation, createdUser, client)
+	}
+	return createdUser, nil
+}
+
+func (s *AuthService) Login(email, password string, client models.ClientInfo) (*models.User, error) {
+	// Users from an external backend may not exist locally until their first login
+	existing, err := s.userRepo.FindUserByEmail(email)
+	if err != nil {
+		existing = nil
+	}
+
+	// Locked accounts are refused before the password is even checked, so guesses stay blocked
+	// for the whole lockout. They look like a wrong password, which neither confirms the account
+	// nor the lock to the caller; the owner has been told about the lock by email.
+	if existing != nil && existing.IsLocked() {
+		utils.CheckDummyPasswordHash(password)
+		return nil, ErrInvalidCredentials
+	}
+
+	// The backends may resolve the login to another user than the typed address names,
+	// e.g. a directory user signing in with their user principal name, so the lockout
+	// is checked and counted on the user they return
+	user, err := s.authenticate(email, password)
+	if err != nil {
+		if user != nil && !user.IsLocked() {
+			if err := s.lockoutService.RecordFailure(user, client); err != nil && !errors.Is(err, ErrAccountLocked) {
+				log.Printf("Failed to record failed login for user %d: %v", user.ID, err)
+			}
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.lockoutService.CheckSignIn(user, ErrInvalidCredentials); err != nil {
+		return nil, err
+	}
+
+	if err := s.lockoutService.RecordSuccess(user); err != nil {
+		return nil, err
+	}
+
+	// Expired passwords must be replaced through a password reset
+	if s.passwordPolicy.Expired(user) {
+		return nil, ErrPasswordExpired
+	}
+
+	// Unverified addresses may be refused outright
+	if user.EmailVerifiedAt == nil && EmailVerificationPolicy() == EmailVerificationBlock {
+		return nil, ErrEmailNotVerified
+	}
+
+	return user, nil
+}
+
+// AntiEnumerationEnabled reports whether ANTI_ENUMERATION is set, making login and registration
+// answer the same way, in the same time, whether or not an account exists
+func AntiEnumerationEnabled() bool {
+	return config.GetEnvBool("ANTI_ENUMERATION", false)
 }
 
-func (s *AuthService) Login(email, password string) (*models.User, error) {
-	// Find user by email
-	user, err := s.userRepo.FindUserByEmail(email)
+// authenticate tries each authenticator in order and returns the user of the first that accepts the login.
+// If none does, it returns the first user an authenticator recognized, if any, with ErrInvalidCredentials.
+func (s *AuthService) authenticate(email, password string) (*models.User, error) {
+	var recognized *models.User
+	for _, authenticator := range s.authenticators {
+		user, err := authenticator.Authenticate(email, password)
+		if err == nil {
+			return user, nil
+		}
+
+		// An unreachable backend must not stop the others from being tried
+		if !errors.Is(err, ErrInvalidCredentials) {
+			log.Printf("%s authenticator failed: %v", authenticator.Name(), err)
+		} else if recognized == nil {
+			recognized = user
+		}
+	}
+	return recognized, ErrInvalidCredentials
+}
+
+// GetUser returns the user with the given ID
+func (s *AuthService) GetUser(userID uint) (*models.User, error) {
+	return s.userRepo.GetUserByID(userID)
+}
+
+// UpdateProfile changes the names of a user, leaving nil fields untouched
+func (s *AuthService) UpdateProfile(userID uint, firstName, lastName *string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(userID)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, err
 	}
 
-	// Verify password
-	if !utils.CheckPasswordHash(password, user.Password) {
-		return nil, errors.New("invalid credentials")
+	if firstName != nil {
+		user.FirstName = strings.TrimSpace(*firstName)
+	}
+	if lastName != nil {
+		user.LastName = strings.TrimSpace(*lastName)
+	}
+	if user.FirstName == "" || user.LastName == "" {
+		return nil, errors.New("first and last name cannot be empty")
 	}
 
+	if err := s.userRepo.UpdateProfile(user.ID, user.FirstName, user.LastName); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
+
+// BootstrapAdmins grants the admin role to the existing users with the given emails
+func (s *AuthService) BootstrapAdmins(emails []string) {
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		if err := s.userRepo.GrantRole(email, RoleAdmin); err != nil {
+			log.Printf("Failed to grant admin role to %s: %v", email, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"JwtSecurityImplementation/repositories"
+)
+
+// newTestDirectoryAuthService signs users in against the test directory first, then local passwords
+func newTestDirectoryAuthService(t *testing.T) (*AuthService, *repositories.UserRepository) {
+	db := newTestDB(t)
+	_, ldapAuthenticator, userRepo := newTestLDAPAuthenticator(t, db)
+
+	service := NewAuthService(
+		userRepo,
+		newTestLockoutService(db, userRepo),
+		NewPasswordPolicy(repositories.NewPasswordHistoryRepository(db), nil),
+		nil,
+		ldapAuthenticator,
+		NewLocalAuthenticator(userRepo),
+	)
+	return service, userRepo
+}
+
+func TestLoginByUserPrincipalNameLocksTheDirectoryUser(t *testing.T) {
+	t.Setenv("LOCKOUT_THRESHOLD", "3")
+	service, userRepo := newTestDirectoryAuthService(t)
+
+	user, err := service.Login("jdoe@corp.example.com", "directory-secret", testClient)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	// The typed login is no local address, the failures still count against Jane
+	for i := 0; i < 3; i++ {
+		if _, err := service.Login("jdoe@corp.example.com", "wrong", testClient); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Login with a wrong password returned %v, want ErrInvalidCredentials", err)
+		}
+	}
+
+	stored, err := userRepo.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if !stored.IsLocked() {
+		t.Fatalf("user is not locked after %d failed logins", stored.FailedLoginAttempts)
+	}
+
+	// The right password does not get past the lock, and looks like a wrong one
+	if _, err := service.Login("jdoe@corp.example.com", "directory-secret", testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login of a locked user returned %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := service.Login("jane@example.com", "directory-secret", testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login of a locked user by email returned %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLoginByUserPrincipalNameResetsFailures(t *testing.T) {
+	t.Setenv("LOCKOUT_THRESHOLD", "3")
+	service, userRepo := newTestDirectoryAuthService(t)
+
+	user, err := service.Login("jdoe@corp.example.com", "directory-secret", testClient)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if _, err := service.Login("jdoe@corp.example.com", "wrong", testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login with a wrong password returned %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := service.Login("jdoe@corp.example.com", "directory-secret", testClient); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	stored, err := userRepo.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if stored.FailedLoginAttempts != 0 {
+		t.Errorf("failed attempts = %d after a successful login, want 0", stored.FailedLoginAttempts)
+	}
+}
+
+func TestLoginByUserPrincipalNameRefusesSuspendedUsers(t *testing.T) {
+	service, userRepo := newTestDirectoryAuthService(t)
+
+	user, err := service.Login("jdoe@corp.example.com", "directory-secret", testClient)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if err := userRepo.Suspend(user.ID, "left the company"); err != nil {
+		t.Fatalf("Suspend: %v", err)
+	}
+
+	if _, err := service.Login("jdoe@corp.example.com", "directory-secret", testClient); !errors.Is(err, ErrAccountSuspended) {
+		t.Errorf("Login of a suspended user returned %v, want ErrAccountSuspended", err)
+	}
+
+	t.Setenv("ANTI_ENUMERATION", "true")
+	if _, err := service.Login("jdoe@corp.example.com", "directory-secret", testClient); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login of a suspended user with anti-enumeration returned %v, want ErrInvalidCredentials", err)
+	}
+}
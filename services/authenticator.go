@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/ldapauth"
+	"JwtSecurityImplementation/repositories"
+	"JwtSecurityImplementation/utils"
+)
+
+// ErrInvalidCredentials is returned by an Authenticator that does not accept the credentials
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Authenticator checks a login against one credential backend.
+// AuthService tries its authenticators in order until one accepts the login.
+type Authenticator interface {
+	Name() string
+	// Authenticate returns the local user for valid credentials, or ErrInvalidCredentials.
+	// When the login names a known user but the password is wrong, that user is returned
+	// along with ErrInvalidCredentials so the failure counts against the right account.
+	Authenticate(email, password string) (*models.User, error)
+}
+
+// LocalAuthenticator checks the password hash stored in the users table
+type LocalAuthenticator struct {
+	userRepo *repositories.UserRepository
+}
+
+func NewLocalAuthenticator(userRepo *repositories.UserRepository) *LocalAuthenticator {
+	return &LocalAuthenticator{userRepo: userRepo}
+}
+
+func (a *LocalAuthenticator) Name() string {
+	return "local"
+}
+
+func (a *LocalAuthenticator) Authenticate(email, password string) (*models.User, error) {
+	user, err := a.userRepo.FindUserByEmail(email)
+	if err != nil || user.Password == "" {
+		// Users provisioned by an external backend have no local password either
+		if AntiEnumerationEnabled() {
+			utils.CheckDummyPasswordHash(password)
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return user, ErrInvalidCredentials
+	}
+
+	// The plain password is only at hand now, so move outdated hashes to the current algorithm
+	if utils.PasswordNeedsRehash(user.Password) {
+		if hashedPassword, err := utils.HashPassword(password); err != nil {
+			log.Printf("Failed to rehash password for user %d: %v", user.ID, err)
+		} else if err := a.userRepo.UpdatePasswordHash(user.ID, hashedPassword); err != nil {
+			log.Printf("Failed to store rehashed password for user %d: %v", user.ID, err)
+		} else {
+			user.Password = hashedPassword
+		}
+	}
+	return user, nil
+}
+
+// BuildAuthenticatorChain creates the authenticators named in chain, e.g. "ldap,local", in that order
+func BuildAuthenticatorChain(chain string, userRepo *repositories.UserRepository, ldapClient *ldapauth.Client) ([]Authenticator, error) {
+	var authenticators []Authenticator
+	for _, name := range strings.Split(chain, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+			continue
+		case "local":
+			authenticators = append(authenticators, NewLocalAuthenticator(userRepo))
+		case "ldap":
+			if ldapClient == nil {
+				return nil, errors.New("the ldap authenticator requires LDAP_URL to be set")
+			}
+			authenticators = append(authenticators, NewLDAPAuthenticator(ldapClient, userRepo))
+		default:
+			return nil, fmt.Errorf("unknown authenticator %q", name)
+		}
+	}
+
+	if len(authenticators) == 0 {
+		return nil, errors.New("at least one authenticator must be configured")
+	}
+	return authenticators, nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+	"JwtSecurityImplementation/utils"
+)
+
+// usePepperVersion makes version current with the given peppers configured
+func usePepperVersion(t *testing.T, version string, peppers map[string]string) {
+	t.Helper()
+	for key, value := range peppers {
+		t.Setenv("PASSWORD_PEPPER_"+key, value)
+	}
+	t.Setenv("PASSWORD_PEPPER_VERSION", version)
+	if err := utils.LoadPasswordPeppers(); err != nil {
+		t.Fatalf("LoadPasswordPeppers: %v", err)
+	}
+}
+
+func TestLocalAuthenticatorMovesPasswordsToTheCurrentPepper(t *testing.T) {
+	useCheapPasswordHashes(t)
+	t.Cleanup(func() { utils.LoadPasswordPeppers() })
+
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	authenticator := NewLocalAuthenticator(userRepo)
+
+	usePepperVersion(t, "1", map[string]string{"1": "first pepper, long enough"})
+	hash, err := utils.HashPassword("password")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	user := createTestUser(t, userRepo, &models.User{FirstName: "Jane", Email: "jane@example.com", Password: hash})
+
+	usePepperVersion(t, "2", map[string]string{"2": "second pepper, long enough"})
+	if _, err := authenticator.Authenticate(user.Email, "password"); err != nil {
+		t.Fatalf("Authenticate with the previous pepper: %v", err)
+	}
+
+	stored, err := userRepo.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if !strings.HasPrefix(stored.Password, "$pepper$k=2$") {
+		t.Errorf("stored hash = %q after login, want it moved to pepper version 2", stored.Password)
+	}
+	if _, err := authenticator.Authenticate(user.Email, "password"); err != nil {
+		t.Errorf("Authenticate after the migration: %v", err)
+	}
+}
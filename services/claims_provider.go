@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+
+	"JwtSecurityImplementation/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ClaimsProvider adds custom claims to every access token the TokenService mints.
+// Providers are registered at startup and must be safe for concurrent use.
+type ClaimsProvider interface {
+	// Namespace prefixes every claim returned by the provider, e.g. "billing"
+	// turns "customer_id" into "billing:customer_id"
+	Namespace() string
+
+	// Claims returns the claims to add to an access token for the given user
+	Claims(user *models.User) (map[string]interface{}, error)
+}
+
+// reservedClaims can only be set by the TokenService itself
+var reservedClaims = map[string]bool{
+	"iss":            true,
+	"sub":            true,
+	"aud":            true,
+	"exp":            true,
+	"nbf":            true,
+	"iat":            true,
+	"jti":            true,
+	"user_id":        true,
+	"first_name":     true,
+	"last_name":      true,
+	"email":          true,
+	"email_verified": true,
+	"token_type":     true,
+	"sid":            true,
+	"remember_me":    true,
+	"scopes":         true,
+	"roles":          true,
+	"tenant":         true,
+}
+
+var namespacePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// RegisterClaimsProvider adds a provider that is called whenever an access token is minted
+func (ts *TokenService) RegisterClaimsProvider(provider ClaimsProvider) error {
+	namespace := provider.Namespace()
+	if !namespacePattern.MatchString(namespace) {
+		return fmt.Errorf("invalid claims namespace %q", namespace)
+	}
+	if reservedClaims[namespace] {
+		return fmt.Errorf("claims namespace %q is reserved", namespace)
+	}
+
+	for _, existing := range ts.claimsProviders {
+		if existing.Namespace() == namespace {
+			return fmt.Errorf("claims namespace %q is already registered", namespace)
+		}
+	}
+
+	ts.claimsProviders = append(ts.claimsProviders, provider)
+	return nil
+}
+
+// applyClaimsProviders merges the namespaced claims of every registered provider
+func (ts *TokenService) applyClaimsProviders(user *models.User, claims jwt.MapClaims) error {
+	for _, provider := range ts.claimsProviders {
+		extra, err := provider.Claims(user)
+		if err != nil {
+			return fmt.Errorf("claims provider %q failed: %w", provider.Namespace(), err)
+		}
+
+		for key, value := range extra {
+			// The namespace prefix keeps every key clear of the reserved claims
+			if key == "" {
+				return fmt.Errorf("claims provider %q returned an empty claim name", provider.Namespace())
+			}
+			claims[provider.Namespace()+":"+key] = value
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+)
+
+// stubClaimsProvider returns fixed claims, or fails if err is set
+type stubClaimsProvider struct {
+	namespace string
+	claims    map[string]interface{}
+	err       error
+}
+
+func (p stubClaimsProvider) Namespace() string {
+	return p.namespace
+}
+
+func (p stubClaimsProvider) Claims(*models.User) (map[string]interface{}, error) {
+	return p.claims, p.err
+}
+
+func TestClaimsProviderCannotOverrideTokenClaims(t *testing.T) {
+	store := repositories.NewMemoryTokenStore()
+	tokenService := NewTokenService(store, nil, nil)
+	err := tokenService.RegisterClaimsProvider(stubClaimsProvider{namespace: "billing", claims: map[string]interface{}{
+		"user_id":     999,
+		"sid":         "forged-session",
+		"token_type":  "refresh",
+		"roles":       []string{RoleAdmin},
+		"tenant":      "other-tenant",
+		"customer_id": "cus_123",
+	}})
+	if err != nil {
+		t.Fatalf("RegisterClaimsProvider: %v", err)
+	}
+
+	user := &models.User{FirstName: "Jane", Email: "jane@example.com", Roles: []string{"user"}, Tenant: "acme"}
+	user.ID = 7
+	tokens, err := tokenService.GenerateTokenPair(user, false, testClient)
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+	_, claims, err := tokenService.ValidateToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"user_id":    float64(7),
+		"sid":        tokens.SessionID,
+		"token_type": "access",
+		"tenant":     "acme",
+	}
+	for claim, value := range want {
+		if claims[claim] != value {
+			t.Errorf("claim %s = %v, want %v", claim, claims[claim], value)
+		}
+	}
+	if roles, _ := claims["roles"].([]interface{}); len(roles) != 1 || roles[0] != "user" {
+		t.Errorf("claim roles = %v, want [user]", claims["roles"])
+	}
+
+	// The provider's claims all land under its namespace
+	for _, claim := range []string{"user_id", "sid", "token_type", "roles", "tenant", "customer_id"} {
+		if _, ok := claims["billing:"+claim]; !ok {
+			t.Errorf("claim billing:%s is missing", claim)
+		}
+	}
+}
+
+func TestClaimsProviderErrorAbortsTokenIssuance(t *testing.T) {
+	store := repositories.NewMemoryTokenStore()
+	tokenService := NewTokenService(store, nil, nil)
+	providerErr := errors.New("billing service unavailable")
+	if err := tokenService.RegisterClaimsProvider(stubClaimsProvider{namespace: "billing", err: providerErr}); err != nil {
+		t.Fatalf("RegisterClaimsProvider: %v", err)
+	}
+
+	user := &models.User{FirstName: "Jane", Email: "jane@example.com"}
+	user.ID = 7
+	tokens, err := tokenService.GenerateTokenPair(user, false, testClient)
+	if !errors.Is(err, providerErr) || tokens != nil {
+		t.Fatalf("GenerateTokenPair = %v, %v, want no tokens and the provider error", tokens, err)
+	}
+
+	sessions, err := store.ListActiveSessions(user.ID)
+	if err != nil {
+		t.Fatalf("ListActiveSessions: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("%d sessions left active after token issuance failed", len(sessions))
+	}
+}
+
+func TestClaimsProviderEmptyClaimNameAbortsTokenIssuance(t *testing.T) {
+	tokenService := NewTokenService(repositories.NewMemoryTokenStore(), nil, nil)
+	if err := tokenService.RegisterClaimsProvider(stubClaimsProvider{namespace: "billing", claims: map[string]interface{}{"": "x"}}); err != nil {
+		t.Fatalf("RegisterClaimsProvider: %v", err)
+	}
+
+	user := &models.User{Email: "jane@example.com"}
+	user.ID = 7
+	if _, err := tokenService.GenerateTokenPair(user, false, testClient); err == nil {
+		t.Error("GenerateTokenPair accepted an empty claim name")
+	}
+}
+
+func TestRegisterClaimsProviderValidatesNamespace(t *testing.T) {
+	tokenService := NewTokenService(repositories.NewMemoryTokenStore(), nil, nil)
+	if err := tokenService.RegisterClaimsProvider(stubClaimsProvider{namespace: "billing"}); err != nil {
+		t.Fatalf("RegisterClaimsProvider: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+	}{
+		{"empty", ""},
+		{"upper case", "Billing"},
+		{"contains separator", "billing:v2"},
+		{"starts with digit", "1billing"},
+		{"reserved claim user_id", "user_id"},
+		{"reserved claim roles", "roles"},
+		{"reserved claim tenant", "tenant"},
+		{"reserved claim sid", "sid"},
+		{"already registered", "billing"},
+	}
+	for _, tt := range tests {
+		if err := tokenService.RegisterClaimsProvider(stubClaimsProvider{namespace: tt.namespace}); err == nil {
+			t.Errorf("%s: RegisterClaimsProvider(%q) succeeded, want an error", tt.name, tt.namespace)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+)
+
+func TestEmailCodeLoginRefusedWhilePasswordResetRequired(t *testing.T) {
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	mail := &recordingMailer{}
+	service := NewEmailCodeService(userRepo, repositories.NewEmailCodeRepository(db), newTestLockoutService(db, userRepo), mail)
+	user := createTestUser(t, userRepo, &models.User{FirstName: "Jane", Email: "jane@example.com"})
+
+	login := func() error {
+		t.Helper()
+		mail.messages = nil
+		if err := service.Send(user, EmailCodeLogin); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		code := regexp.MustCompile(`\b\d{6}\b`).FindString(mail.messages[0].Body)
+		_, err := service.Login(user.Email, code, testClient)
+		return err
+	}
+
+	t.Setenv("EMAIL_CODE_RESEND_INTERVAL", "0")
+	if err := login(); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if err := userRepo.RequirePasswordReset(user.ID); err != nil {
+		t.Fatalf("RequirePasswordReset: %v", err)
+	}
+	if err := login(); !errors.Is(err, ErrPasswordResetRequired) {
+		t.Errorf("Login while a password reset is required returned %v, want ErrPasswordResetRequired", err)
+	}
+}
+
+func TestEmailCodeLoginReportsLockLikeWrongCode(t *testing.T) {
+	t.Setenv("LOCKOUT_THRESHOLD", "2")
+	t.Setenv("EMAIL_CODE_RESEND_INTERVAL", "0")
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	mail := &recordingMailer{}
+	service := NewEmailCodeService(userRepo, repositories.NewEmailCodeRepository(db), newTestLockoutService(db, userRepo), mail)
+	user := createTestUser(t, userRepo, &models.User{FirstName: "Jane", Email: "jane@example.com"})
+
+	if err := service.Send(user, EmailCodeLogin); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	code := regexp.MustCompile(`\b\d{6}\b`).FindString(mail.messages[0].Body)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	// The second wrong code locks the account; that attempt and the right code afterwards
+	// get the same answer as any wrong code
+	for i, attempt := range []string{wrong, wrong, code} {
+		if _, err := service.Login(user.Email, attempt, testClient); !errors.Is(err, ErrInvalidEmailCode) {
+			t.Errorf("attempt %d returned %v, want ErrInvalidEmailCode", i, err)
+		}
+	}
+
+	stored, err := userRepo.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if !stored.IsLocked() {
+		t.Error("account not locked after the wrong codes")
+	}
+}
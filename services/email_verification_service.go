@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/mailer"
+	"JwtSecurityImplementation/repositories"
+)
+
+const purposeEmailVerification = "email_verification"
+
+// Email verification policies applied at login to users who have not verified their address
+const (
+	EmailVerificationAllow    = "allow"
+	EmailVerificationRestrict = "restrict"
+	EmailVerificationBlock    = "block"
+)
+
+// ErrEmailNotVerified is returned when login is blocked until the email address is verified
+var ErrEmailNotVerified = errors.New("email address has not been verified")
+
+type EmailVerificationService struct {
+	userRepo      *repositories.UserRepository
+	oneTimeTokens *OneTimeTokenService
+	mailer        mailer.Mailer
+}
+
+func NewEmailVerificationService(userRepo *repositories.UserRepository, oneTimeTokens *OneTimeTokenService, mailer mailer.Mailer) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo:      userRepo,
+		oneTimeTokens: oneTimeTokens,
+		mailer:        mailer,
+	}
+}
+
+// SendVerification emails the user a signed, single-use link to confirm their address
+func (s *EmailVerificationService) SendVerification(user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return errors.New("email address is already verified")
+	}
+
+	ttl := time.Minute * time.Duration(config.GetEnvInt("EMAIL_VERIFICATION_TTL", 60*24))
+	token, err := s.oneTimeTokens.Issue(user.ID, purposeEmailVerification, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/auth/verify-email?token=%s", config.GetEnv("APP_BASE_URL", "http://localhost:8080"), url.QueryEscape(token))
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in %s and can only be used once.\n", user.FirstName, link, ttl),
+	})
+}
+
+// ResendVerification sends a new verification link, invalidating any earlier one
+func (s *EmailVerificationService) ResendVerification(userID uint) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	return s.SendVerification(user)
+}
+
+// VerifyEmail consumes a verification token and marks the user's address as verified
+func (s *EmailVerificationService) VerifyEmail(token string) error {
+	record, err := s.oneTimeTokens.Consume(token, purposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	return s.userRepo.MarkEmailVerified(record.UserID)
+}
+
+// NotifyRegistrationAttempt tells the owner of an address that someone tried to register it again,
+// which is sent instead of an error when registration must not reveal existing accounts
+func (s *EmailVerificationService) NotifyRegistrationAttempt(email string) error {
+	user, err := s.userRepo.FindUserByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	baseURL := config.GetEnv("APP_BASE_URL", "http://localhost:8080")
+	resetURL := config.GetEnv("PASSWORD_RESET_URL", baseURL+"/reset-password")
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Someone tried to create an account with your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nSomeone tried to create a new account with this email address, which already has one.\n\n"+
+			"If it was you, sign in at %s instead. If you forgot your password, you can reset it from %s.\n"+
+			"If it was not you, you can ignore this email; your account has not been changed.\n", user.FirstName, baseURL, resetURL),
+	})
+}
+
+// EmailVerificationPolicy returns the configured EMAIL_VERIFICATION_POLICY
+func EmailVerificationPolicy() string {
+	switch policy := config.GetEnv("EMAIL_VERIFICATION_POLICY", EmailVerificationAllow); policy {
+	case EmailVerificationRestrict, EmailVerificationBlock:
+		return policy
+	default:
+		return EmailVerificationAllow
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/mailer"
+	"JwtSecurityImplementation/repositories"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB returns a migrated SQLite database that lives as long as the test
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	err = db.AutoMigrate(&models.User{}, &models.BlacklistedToken{}, &models.Session{}, &models.PersonalAccessToken{}, &models.OneTimeToken{}, &models.PasswordHistory{}, &models.ExternalIdentity{}, &models.Invitation{}, &models.EmailCode{}, &models.WebAuthnCredential{}, &models.RecoveryCode{})
+	if err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	// SQLite has no nvarchar(max), so the audit table is created by hand
+	err = db.Exec(`CREATE TABLE audit_events (id integer PRIMARY KEY AUTOINCREMENT, type text NOT NULL, user_id integer,
+		actor_id integer, ip_address text, details text, created_at datetime)`).Error
+	if err != nil {
+		t.Fatalf("create audit table: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("database handle: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db
+}
+
+// newTestLockoutService returns a LockoutService that discards its emails
+func newTestLockoutService(db *gorm.DB, userRepo *repositories.UserRepository) *LockoutService {
+	return NewLockoutService(
+		userRepo,
+		NewAuditService(repositories.NewAuditRepository(db)),
+		NewOneTimeTokenService(repositories.NewOneTimeTokenRepository(db)),
+		mailer.NewLogMailer("test@example.com"),
+	)
+}
+
+// createTestUser stores a user or fails the test
+func createTestUser(t *testing.T, userRepo *repositories.UserRepository, user *models.User) *models.User {
+	t.Helper()
+
+	created, err := userRepo.CreateUser(user)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return created
+}
+
+// testClient is the caller of requests made in tests
+var testClient = models.ClientInfo{IPAddress: "192.0.2.1", UserAgent: "test-agent"}
+
+// recordingMailer keeps the messages it is asked to send
+type recordingMailer struct {
+	messages []mailer.Message
+}
+
+func (m *recordingMailer) Send(message mailer.Message) error {
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// useCheapPasswordHashes makes password hashing as fast as the settings allow
+func useCheapPasswordHashes(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	t.Setenv("ARGON2_MEMORY", "8192")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+}
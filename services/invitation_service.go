@@ -0,0 +1,213 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/mailer"
+	"JwtSecurityImplementation/repositories"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Registration modes for /auth/register
+const (
+	RegistrationOpen     = "open"
+	RegistrationInvite   = "invite"
+	RegistrationDomain   = "domain"
+	RegistrationDisabled = "disabled"
+)
+
+var (
+	// ErrRegistrationDisabled is returned by Register when REGISTRATION_MODE is disabled
+	ErrRegistrationDisabled = errors.New("registration is disabled")
+	// ErrInvitationRequired is returned by Register when only invited users can register
+	ErrInvitationRequired = errors.New("an invitation is required to register")
+	// ErrEmailDomainNotAllowed is returned by Register for addresses outside REGISTRATION_ALLOWED_DOMAINS
+	ErrEmailDomainNotAllowed = errors.New("email domain is not allowed to register")
+	// ErrInvitationInvalid is returned for codes that are forged, expired, revoked, used or meant for another address
+	ErrInvitationInvalid = errors.New("invitation is invalid, expired or has already been used")
+)
+
+// InvitationService issues signed invitation codes and redeems them at registration
+type InvitationService struct {
+	repo         *repositories.InvitationRepository
+	auditService *AuditService
+	mailer       mailer.Mailer
+}
+
+func NewInvitationService(repo *repositories.InvitationRepository, auditService *AuditService, mailer mailer.Mailer) *InvitationService {
+	return &InvitationService{
+		repo:         repo,
+		auditService: auditService,
+		mailer:       mailer,
+	}
+}
+
+// Create issues an invitation, emailed to the address if one is given.
+// The returned code is never stored and cannot be retrieved again.
+func (s *InvitationService) Create(actorID uint, email string, roles []string, tenant string, expiresInDays int, client models.ClientInfo) (*models.Invitation, string, error) {
+	if expiresInDays == 0 {
+		expiresInDays = config.GetEnvInt("INVITATION_EXPIRY_DAYS", 7)
+	}
+	maxDays := config.GetEnvInt("INVITATION_MAX_EXPIRY_DAYS", 30)
+	if expiresInDays < 1 || expiresInDays > maxDays {
+		return nil, "", fmt.Errorf("expiry must be between 1 and %d days", maxDays)
+	}
+
+	invitation := &models.Invitation{
+		ID:          uuid.NewString(),
+		Email:       strings.ToLower(strings.TrimSpace(email)),
+		Roles:       normalizeRoles(roles),
+		Tenant:      strings.TrimSpace(tenant),
+		CreatedByID: actorID,
+		ExpiresAt:   time.Now().AddDate(0, 0, expiresInDays),
+	}
+	if err := s.repo.Create(invitation); err != nil {
+		return nil, "", err
+	}
+
+	claims := jwt.MapClaims{
+		"jti":        invitation.ID,
+		"exp":        invitation.ExpiresAt.Unix(),
+		"token_type": "invitation",
+	}
+	code, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(getJWTSecret()))
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.auditService.Record(models.AuditInvitationCreated, nil, &actorID, client.IPAddress, map[string]interface{}{
+		"invitation": invitation.ID,
+		"email":      invitation.Email,
+		"roles":      invitation.Roles,
+		"tenant":     invitation.Tenant,
+	})
+
+	// The administrator gets the code either way and can pass it on themselves
+	if invitation.Email != "" {
+		if err := s.sendInvitation(invitation, code); err != nil {
+			log.Printf("Failed to send invitation %s: %v", invitation.ID, err)
+		}
+	}
+
+	return invitation, code, nil
+}
+
+// List returns every invitation, or only those that can still be used
+func (s *InvitationService) List(pending bool) ([]models.Invitation, error) {
+	return s.repo.List(pending)
+}
+
+// Revoke stops an invitation from being used
+func (s *InvitationService) Revoke(invitationID string, actorID uint, client models.ClientInfo) error {
+	if err := s.repo.Revoke(invitationID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(models.AuditInvitationRevoked, nil, &actorID, client.IPAddress, map[string]interface{}{
+		"invitation": invitationID,
+	})
+	return nil
+}
+
+// accept verifies an invitation code for the registering address and marks the invitation as accepted.
+// It must be completed with complete once the user exists, or handed back with release.
+func (s *InvitationService) accept(code, email string) (*models.Invitation, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(code, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(getJWTSecret()), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || claims["token_type"] != "invitation" {
+		return nil, ErrInvitationInvalid
+	}
+
+	invitationID, ok := claims["jti"].(string)
+	if !ok {
+		return nil, ErrInvitationInvalid
+	}
+
+	invitation, err := s.repo.Accept(invitationID)
+	if err != nil {
+		return nil, ErrInvitationInvalid
+	}
+
+	// Addressed invitations only admit their addressee
+	if invitation.Email != "" && !strings.EqualFold(invitation.Email, strings.TrimSpace(email)) {
+		s.release(invitation)
+		return nil, ErrInvitationInvalid
+	}
+
+	return invitation, nil
+}
+
+// release makes an accepted invitation usable again after the registration failed
+func (s *InvitationService) release(invitation *models.Invitation) {
+	if err := s.repo.Release(invitation.ID); err != nil {
+		log.Printf("Failed to release invitation %s: %v", invitation.ID, err)
+	}
+}
+
+// complete records the user who registered with the invitation
+func (s *InvitationService) complete(invitation *models.Invitation, user *models.User, client models.ClientInfo) {
+	if err := s.repo.SetAcceptedBy(invitation.ID, user.ID); err != nil {
+		log.Printf("Failed to record acceptance of invitation %s: %v", invitation.ID, err)
+	}
+
+	s.auditService.Record(models.AuditInvitationAccepted, &user.ID, &user.ID, client.IPAddress, map[string]interface{}{
+		"invitation": invitation.ID,
+		"invitedBy":  invitation.CreatedByID,
+	})
+}
+
+// sendInvitation emails the registration link carrying the code
+func (s *InvitationService) sendInvitation(invitation *models.Invitation, code string) error {
+	baseURL := config.GetEnv("APP_BASE_URL", "http://localhost:8080")
+	registerURL := config.GetEnv("INVITATION_URL", baseURL+"/register")
+
+	separator := "?"
+	if strings.Contains(registerURL, "?") {
+		separator = "&"
+	}
+	link := registerURL + separator + "invitation=" + url.QueryEscape(code)
+
+	return s.mailer.Send(mailer.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited to create an account",
+		Body: fmt.Sprintf("Hello,\n\nYou have been invited to create an account. Register by opening the link below:\n\n%s\n\n"+
+			"The invitation expires on %s and can only be used once.\n", link, invitation.ExpiresAt.Format(time.RFC1123)),
+	})
+}
+
+// RegistrationMode returns the configured REGISTRATION_MODE
+func RegistrationMode() string {
+	switch mode := strings.ToLower(config.GetEnv("REGISTRATION_MODE", RegistrationOpen)); mode {
+	case RegistrationInvite, RegistrationDomain, RegistrationDisabled:
+		return mode
+	default:
+		return RegistrationOpen
+	}
+}
+
+// registrationDomainAllowed reports whether the address belongs to one of REGISTRATION_ALLOWED_DOMAINS
+func registrationDomainAllowed(email string) bool {
+	_, domain, ok := strings.Cut(strings.TrimSpace(email), "@")
+	if !ok {
+		return false
+	}
+
+	for _, allowed := range strings.Split(config.GetEnv("REGISTRATION_ALLOWED_DOMAINS", ""), ",") {
+		allowed = strings.TrimPrefix(strings.TrimSpace(allowed), "@")
+		if allowed != "" && strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/ldapauth"
+	"JwtSecurityImplementation/repositories"
+)
+
+// LDAPAuthenticator checks passwords against an LDAP or Active Directory server.
+// A shadow user is created on first login, and the roles mapped from directory
+// groups are synchronized on every login.
+type LDAPAuthenticator struct {
+	client     *ldapauth.Client
+	userRepo   *repositories.UserRepository
+	groupRoles map[string][]string
+}
+
+func NewLDAPAuthenticator(client *ldapauth.Client, userRepo *repositories.UserRepository) *LDAPAuthenticator {
+	return &LDAPAuthenticator{
+		client:     client,
+		userRepo:   userRepo,
+		groupRoles: parseGroupRoles(config.GetEnv("LDAP_GROUP_ROLES", "")),
+	}
+}
+
+func (a *LDAPAuthenticator) Name() string {
+	return "ldap"
+}
+
+func (a *LDAPAuthenticator) Authenticate(email, password string) (*models.User, error) {
+	entry, err := a.client.Authenticate(email, password)
+	if err != nil {
+		if errors.Is(err, ldapauth.ErrUserNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		if errors.Is(err, ldapauth.ErrInvalidCredentials) {
+			// The failure belongs to the shadow user of the entry, not to the typed login,
+			// which may be a user principal name. Directory users without one have nothing to count.
+			if entry != nil {
+				if user, err := a.userRepo.FindUserByEmail(entryEmail(entry, email)); err == nil {
+					return user, ErrInvalidCredentials
+				}
+			}
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	entry.Email = entryEmail(entry, email)
+
+	user, err := a.userRepo.FindUserByEmail(entry.Email)
+	if err != nil {
+		if user, err = a.createShadowUser(entry); err != nil {
+			return nil, err
+		}
+	} else if entry.FirstName != "" && (entry.FirstName != user.FirstName || entry.LastName != user.LastName) {
+		if err := a.userRepo.UpdateProfile(user.ID, entry.FirstName, entry.LastName); err != nil {
+			return nil, err
+		}
+		user.FirstName, user.LastName = entry.FirstName, entry.LastName
+	}
+
+	if err := a.syncRoles(user, entry.Groups); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// entryEmail returns the address of a directory entry, falling back to the login for entries without one
+func entryEmail(entry *ldapauth.Entry, login string) string {
+	if entry.Email == "" {
+		return login
+	}
+	return entry.Email
+}
+
+// createShadowUser stores a local user without a password for a directory account.
+// The directory vouches for the address, so it counts as verified.
+func (a *LDAPAuthenticator) createShadowUser(entry *ldapauth.Entry) (*models.User, error) {
+	firstName := entry.FirstName
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(entry.Email, "@")
+	}
+
+	now := time.Now()
+	return a.userRepo.CreateUser(&models.User{
+		FirstName:       firstName,
+		LastName:        entry.LastName,
+		Email:           entry.Email,
+		EmailVerifiedAt: &now,
+	})
+}
+
+// syncRoles replaces the roles managed by LDAP_GROUP_ROLES with those of the user's groups.
+// Roles that no group maps to, e.g. granted by an admin, are left alone.
+func (a *LDAPAuthenticator) syncRoles(user *models.User, groups []string) error {
+	if len(a.groupRoles) == 0 {
+		return nil
+	}
+
+	managed := map[string]bool{}
+	for _, roles := range a.groupRoles {
+		for _, role := range roles {
+			managed[role] = true
+		}
+	}
+
+	granted := map[string]bool{}
+	for _, group := range groups {
+		for _, role := range a.groupRoles[strings.ToLower(group)] {
+			granted[role] = true
+		}
+	}
+
+	roles := []string{}
+	for _, role := range user.Roles {
+		if !managed[role] {
+			roles = append(roles, role)
+		}
+	}
+	for role := range granted {
+		roles = append(roles, role)
+	}
+
+	if sameRoles(user.Roles, roles) {
+		return nil
+	}
+	if err := a.userRepo.SetRoles(user.ID, roles); err != nil {
+		return err
+	}
+	user.Roles = roles
+	return nil
+}
+
+// parseGroupRoles reads "<group DN>:<role>[;<group DN>:<role>...]"; group DNs are compared case-insensitively
+func parseGroupRoles(value string) map[string][]string {
+	groupRoles := map[string][]string{}
+	for _, mapping := range strings.Split(value, ";") {
+		separator := strings.LastIndex(mapping, ":")
+		if separator < 0 {
+			continue
+		}
+
+		group := strings.ToLower(strings.TrimSpace(mapping[:separator]))
+		role := strings.TrimSpace(mapping[separator+1:])
+		if group != "" && role != "" {
+			groupRoles[group] = append(groupRoles[group], role)
+		}
+	}
+	return groupRoles
+}
+
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := map[string]bool{}
+	for _, role := range a {
+		set[role] = true
+	}
+	for _, role := range b {
+		if !set[role] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"JwtSecurityImplementation/pkg/ldapauth"
+	"JwtSecurityImplementation/pkg/ldapauth/ldaptest"
+	"JwtSecurityImplementation/repositories"
+
+	"gorm.io/gorm"
+)
+
+const (
+	testDirectoryUserDN = "cn=Jane Doe,ou=people,dc=example,dc=com"
+	testAdminsGroupDN   = "cn=Admins,ou=groups,dc=example,dc=com"
+	testStaffGroupDN    = "cn=Staff,ou=groups,dc=example,dc=com"
+)
+
+// newTestDirectory starts a directory holding Jane Doe, who signs in as jane@example.com
+// or with her user principal name jdoe@corp.example.com
+func newTestDirectory(t *testing.T) (*ldaptest.Server, *ldapauth.Client) {
+	server := ldaptest.NewServer(t,
+		ldaptest.Entry{DN: "cn=service,dc=example,dc=com", Password: "service-secret"},
+		ldaptest.Entry{
+			DN:       testDirectoryUserDN,
+			Password: "directory-secret",
+			Attributes: map[string][]string{
+				"objectClass":       {"user"},
+				"mail":              {"jane@example.com"},
+				"userPrincipalName": {"jdoe@corp.example.com"},
+				"givenName":         {"Jane"},
+				"sn":                {"Doe"},
+				"memberOf":          {testAdminsGroupDN, testStaffGroupDN},
+			},
+		},
+	)
+
+	client := ldapauth.NewClient(ldapauth.Config{
+		URL:          server.URL,
+		BindDN:       "cn=service,dc=example,dc=com",
+		BindPassword: "service-secret",
+		BaseDN:       "dc=example,dc=com",
+		UserFilter:   "(&(objectClass=user)(|(mail=%s)(userPrincipalName=%s)))",
+		Timeout:      5 * time.Second,
+	})
+	return server, client
+}
+
+func newTestLDAPAuthenticator(t *testing.T, db *gorm.DB) (*ldaptest.Server, *LDAPAuthenticator, *repositories.UserRepository) {
+	t.Setenv("LDAP_GROUP_ROLES", strings.ToUpper(testAdminsGroupDN)+":admin;"+testStaffGroupDN+":staff")
+
+	server, client := newTestDirectory(t)
+	userRepo := repositories.NewUserRepository(db)
+	return server, NewLDAPAuthenticator(client, userRepo), userRepo
+}
+
+func sortedRoles(roles []string) []string {
+	sorted := append([]string(nil), roles...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func TestLDAPAuthenticatorCreatesShadowUsers(t *testing.T) {
+	_, authenticator, userRepo := newTestLDAPAuthenticator(t, newTestDB(t))
+
+	user, err := authenticator.Authenticate("jdoe@corp.example.com", "directory-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if user.Email != "jane@example.com" || user.FirstName != "Jane" || user.EmailVerifiedAt == nil || user.Password != "" {
+		t.Errorf("shadow user = %+v, want verified jane@example.com without a password", user)
+	}
+
+	stored, err := userRepo.FindUserByEmail("jane@example.com")
+	if err != nil || stored.ID != user.ID {
+		t.Fatalf("shadow user was not stored: %v", err)
+	}
+}
+
+func TestLDAPAuthenticatorSyncsGroupRoles(t *testing.T) {
+	server, authenticator, userRepo := newTestLDAPAuthenticator(t, newTestDB(t))
+
+	user, err := authenticator.Authenticate("jane@example.com", "directory-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if roles := sortedRoles(user.Roles); len(roles) != 2 || roles[0] != "admin" || roles[1] != "staff" {
+		t.Fatalf("roles = %v, want [admin staff] from the groups", roles)
+	}
+
+	// A role no group maps to survives the sync, a mapped role follows the group membership
+	if err := userRepo.SetRoles(user.ID, append(user.Roles, "auditor")); err != nil {
+		t.Fatalf("SetRoles: %v", err)
+	}
+	server.SetAttribute(testDirectoryUserDN, "memberOf", testStaffGroupDN)
+
+	user, err = authenticator.Authenticate("jane@example.com", "directory-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if roles := sortedRoles(user.Roles); len(roles) != 2 || roles[0] != "auditor" || roles[1] != "staff" {
+		t.Errorf("roles = %v, want [auditor staff]", roles)
+	}
+
+	stored, err := userRepo.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if roles := sortedRoles(stored.Roles); len(roles) != 2 || roles[0] != "auditor" || roles[1] != "staff" {
+		t.Errorf("stored roles = %v, want [auditor staff]", roles)
+	}
+}
+
+func TestLDAPAuthenticatorReturnsTheShadowUserOnWrongPasswords(t *testing.T) {
+	_, authenticator, _ := newTestLDAPAuthenticator(t, newTestDB(t))
+
+	// Before the first login there is no local user to blame
+	if user, err := authenticator.Authenticate("jdoe@corp.example.com", "wrong"); err != ErrInvalidCredentials || user != nil {
+		t.Fatalf("Authenticate returned %v, %v, want no user and ErrInvalidCredentials", user, err)
+	}
+
+	shadow, err := authenticator.Authenticate("jdoe@corp.example.com", "directory-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	user, err := authenticator.Authenticate("jdoe@corp.example.com", "wrong")
+	if err != ErrInvalidCredentials || user == nil || user.ID != shadow.ID {
+		t.Errorf("Authenticate returned %v, %v, want the shadow user and ErrInvalidCredentials", user, err)
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/mailer"
+	"JwtSecurityImplementation/repositories"
+)
+
+const purposeAccountUnlock = "account_unlock"
+
+var (
+	// ErrAccountLocked is returned when a login is attempted on a locked account
+	ErrAccountLocked = errors.New("account is temporarily locked due to too many failed login attempts")
+	// ErrAccountSuspended is returned when a login or refresh is attempted on a suspended account
+	ErrAccountSuspended = errors.New("account has been suspended")
+	// ErrAccountDisabled is the name ErrAccountSuspended had before account statuses.
+	//
+	// Deprecated: use ErrAccountSuspended.
+	ErrAccountDisabled = ErrAccountSuspended
+)
+
+// LockoutService locks accounts after repeated failed logins.
+// Each lockout lasts twice as long as the previous one, up to LOCKOUT_MAX_DURATION.
+type LockoutService struct {
+	userRepo      *repositories.UserRepository
+	auditService  *AuditService
+	oneTimeTokens *OneTimeTokenService
+	mailer        mailer.Mailer
+}
+
+func NewLockoutService(userRepo *repositories.UserRepository, auditService *AuditService, oneTimeTokens *OneTimeTokenService, mailer mailer.Mailer) *LockoutService {
+	return &LockoutService{
+		userRepo:      userRepo,
+		auditService:  auditService,
+		oneTimeTokens: oneTimeTokens,
+		mailer:        mailer,
+	}
+}
+
+// CheckLocked returns ErrAccountSuspended for suspended accounts and ErrAccountLocked while
+// the user's lockout window is open. Login methods call it through CheckSignIn.
+func (s *LockoutService) CheckLocked(user *models.User) error {
+	if user.IsSuspended() {
+		return ErrAccountSuspended
+	}
+	if user.IsLocked() {
+		return ErrAccountLocked
+	}
+	return nil
+}
+
+// CheckSignIn is CheckLocked for the login methods. A lock is reported as invalid, the error the
+// method returns for a wrong credential, so a caller cannot tell a locked account from a failed
+// attempt; the owner learns about the lock from the email RecordFailure sends. A suspension is
+// only disclosed when anti-enumeration is off.
+func (s *LockoutService) CheckSignIn(user *models.User, invalid error) error {
+	err := s.CheckLocked(user)
+	if errors.Is(err, ErrAccountLocked) || (err != nil && AntiEnumerationEnabled()) {
+		return invalid
+	}
+	return err
+}
+
+// RecordFailure counts a failed login and locks the account once the threshold is reached
+func (s *LockoutService) RecordFailure(user *models.User, client models.ClientInfo) error {
+	threshold := config.GetEnvInt("LOCKOUT_THRESHOLD", 5)
+	if threshold <= 0 {
+		return nil
+	}
+
+	attempts, err := s.userRepo.IncrementFailedLogins(user.ID)
+	if err != nil {
+		return err
+	}
+	if attempts < threshold {
+		return nil
+	}
+
+	duration := lockoutDuration(user.LockoutCount)
+	lockedUntil := time.Now().Add(duration)
+	if err := s.userRepo.LockUser(user.ID, lockedUntil); err != nil {
+		return err
+	}
+
+	s.auditService.Record(models.AuditAccountLocked, &user.ID, nil, client.IPAddress, map[string]interface{}{
+		"failed_attempts": attempts,
+		"lockout_count":   user.LockoutCount + 1,
+		"locked_until":    lockedUntil,
+	})
+
+	// Logins to a locked account look like a wrong password, so the owner learns about the lock here
+	if err := s.sendUnlockEmail(user, lockedUntil); err != nil {
+		log.Printf("Failed to send lockout notice to user %d: %v", user.ID, err)
+	}
+	return ErrAccountLocked
+}
+
+// RecordSuccess clears the failure counters after a successful login
+func (s *LockoutService) RecordSuccess(user *models.User) error {
+	if user.FailedLoginAttempts == 0 && user.LockoutCount == 0 && user.LockedUntil == nil {
+		return nil
+	}
+	return s.userRepo.ResetLoginFailures(user.ID)
+}
+
+// Unlock lifts the lockout of a user on behalf of an administrator
+func (s *LockoutService) Unlock(userID, actorID uint, client models.ClientInfo) error {
+	if _, err := s.userRepo.GetUserByID(userID); err != nil {
+		return err
+	}
+	if err := s.userRepo.ResetLoginFailures(userID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(models.AuditAccountUnlocked, &userID, &actorID, client.IPAddress, map[string]interface{}{
+		"method": "admin",
+	})
+	return nil
+}
+
+// RequestUnlock emails a single-use unlock link if the account exists and is locked.
+// Unknown or unlocked accounts are ignored without error so callers cannot discover accounts.
+func (s *LockoutService) RequestUnlock(email string) error {
+	user, err := s.userRepo.FindUserByEmail(email)
+	if err != nil || !user.IsLocked() {
+		return nil
+	}
+	return s.sendUnlockEmail(user, *user.LockedUntil)
+}
+
+// sendUnlockEmail emails the user a single-use unlock link that is valid while the lock lasts
+func (s *LockoutService) sendUnlockEmail(user *models.User, lockedUntil time.Time) error {
+	token, err := s.oneTimeTokens.Issue(user.ID, purposeAccountUnlock, time.Until(lockedUntil))
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/auth/unlock?token=%s", config.GetEnv("APP_BASE_URL", "http://localhost:8080"), url.QueryEscape(token))
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Unlock your account",
+		Body: fmt.Sprintf("Hello %s,\n\nYour account was locked after too many failed login attempts. Open the link below to unlock it:\n\n%s\n\n"+
+			"If you did not try to sign in, consider changing your password.\n", user.FirstName, link),
+	})
+}
+
+// UnlockWithToken lifts a lockout using the token from an unlock email
+func (s *LockoutService) UnlockWithToken(token string, client models.ClientInfo) error {
+	record, err := s.oneTimeTokens.Consume(token, purposeAccountUnlock)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.ResetLoginFailures(record.UserID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(models.AuditAccountUnlocked, &record.UserID, nil, client.IPAddress, map[string]interface{}{
+		"method": "email",
+	})
+	return nil
+}
+
+// lockoutDuration doubles the base duration for every previous lockout
+func lockoutDuration(previousLockouts int) time.Duration {
+	base := time.Minute * time.Duration(config.GetEnvInt("LOCKOUT_DURATION", 15))
+	maxDuration := time.Minute * time.Duration(config.GetEnvInt("LOCKOUT_MAX_DURATION", 60*24))
+
+	// Cap the exponent so the multiplication cannot overflow
+	exponent := math.Min(float64(previousLockouts), 20)
+	duration := time.Duration(float64(base) * math.Pow(2, exponent))
+	if duration > maxDuration {
+		return maxDuration
+	}
+	return duration
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+)
+
+func TestRecordFailureEmailsUnlockLinkWhenLocking(t *testing.T) {
+	t.Setenv("LOCKOUT_THRESHOLD", "2")
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	mail := &recordingMailer{}
+	service := NewLockoutService(
+		userRepo,
+		NewAuditService(repositories.NewAuditRepository(db)),
+		NewOneTimeTokenService(repositories.NewOneTimeTokenRepository(db)),
+		mail,
+	)
+	user := createTestUser(t, userRepo, &models.User{FirstName: "Jane", Email: "jane@example.com"})
+
+	if err := service.RecordFailure(user, testClient); err != nil {
+		t.Fatalf("RecordFailure below the threshold returned %v", err)
+	}
+	if len(mail.messages) != 0 {
+		t.Fatalf("sent %d emails before the account was locked", len(mail.messages))
+	}
+	if err := service.RecordFailure(user, testClient); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("RecordFailure at the threshold returned %v, want ErrAccountLocked", err)
+	}
+
+	if len(mail.messages) != 1 || mail.messages[0].To != user.Email {
+		t.Fatalf("lock notice not sent to the owner: %+v", mail.messages)
+	}
+	match := regexp.MustCompile(`/auth/unlock\?token=(\S+)`).FindStringSubmatch(mail.messages[0].Body)
+	if match == nil {
+		t.Fatalf("lock notice has no unlock link: %q", mail.messages[0].Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("unescape token: %v", err)
+	}
+
+	if err := service.UnlockWithToken(token, testClient); err != nil {
+		t.Fatalf("UnlockWithToken: %v", err)
+	}
+	stored, err := userRepo.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if stored.IsLocked() {
+		t.Error("user is still locked after following the unlock link")
+	}
+}
+
+func TestCheckSignInReportsLockAsInvalidCredential(t *testing.T) {
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	service := newTestLockoutService(db, userRepo)
+	errInvalid := errors.New("invalid code")
+
+	lockedUntil := time.Now().Add(time.Hour)
+	locked := &models.User{LockedUntil: &lockedUntil}
+	suspended := &models.User{Status: models.UserStatusSuspended}
+
+	for _, test := range []struct {
+		name          string
+		user          *models.User
+		antiEnumerate string
+		want          error
+	}{
+		{"active", &models.User{Status: models.UserStatusActive}, "false", nil},
+		{"locked", locked, "false", errInvalid},
+		{"locked with anti-enumeration", locked, "true", errInvalid},
+		{"suspended", suspended, "false", ErrAccountSuspended},
+		{"suspended with anti-enumeration", suspended, "true", errInvalid},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("ANTI_ENUMERATION", test.antiEnumerate)
+			if err := service.CheckSignIn(test.user, errInvalid); err != test.want {
+				t.Errorf("CheckSignIn = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/mailer"
+	"JwtSecurityImplementation/repositories"
+)
+
+const purposeMagicLink = "magic_link"
+
+// MagicLinkService signs users in with single-use links sent by email.
+// Each link is bound to a secret kept in a cookie on the device that asked for it,
+// so a forwarded or intercepted link is useless elsewhere.
+type MagicLinkService struct {
+	userRepo       *repositories.UserRepository
+	oneTimeTokens  *OneTimeTokenService
+	lockoutService *LockoutService
+	mailer         mailer.Mailer
+}
+
+func NewMagicLinkService(userRepo *repositories.UserRepository, oneTimeTokens *OneTimeTokenService, lockoutService *LockoutService, mailer mailer.Mailer) *MagicLinkService {
+	return &MagicLinkService{
+		userRepo:       userRepo,
+		oneTimeTokens:  oneTimeTokens,
+		lockoutService: lockoutService,
+		mailer:         mailer,
+	}
+}
+
+// NewDeviceSecret returns a random secret to bind a magic link to the requesting device
+func NewDeviceSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// MagicLinkTTL returns how long a magic link stays valid
+func MagicLinkTTL() time.Duration {
+	return time.Minute * time.Duration(config.GetEnvInt("MAGIC_LINK_TTL", 10))
+}
+
+// SendLink emails a login link bound to deviceSecret if the account exists.
+// Unknown addresses are ignored without error so callers cannot discover accounts.
+func (s *MagicLinkService) SendLink(email, deviceSecret string) error {
+	user, err := s.userRepo.FindUserByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	ttl := MagicLinkTTL()
+	token, err := s.oneTimeTokens.IssueBound(user.ID, purposeMagicLink, ttl, hashDeviceSecret(deviceSecret))
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/auth/magic-link?token=%s", config.GetEnv("APP_BASE_URL", "http://localhost:8080"), url.QueryEscape(token))
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Hello %s,\n\nOpen the link below in the same browser you requested it from to sign in:\n\n%s\n\n"+
+			"The link expires in %s and can only be used once. If you did not request it, you can ignore this email.\n", user.FirstName, link, ttl),
+	})
+}
+
+// ConsumeLink signs a user in with a magic link presented together with its device secret.
+// Following the link proves control of the address, so the email counts as verified.
+func (s *MagicLinkService) ConsumeLink(token, deviceSecret string) (*models.User, error) {
+	if deviceSecret == "" {
+		return nil, errors.New("open the link in the same browser you requested it from")
+	}
+
+	record, err := s.oneTimeTokens.ConsumeBound(token, purposeMagicLink, hashDeviceSecret(deviceSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lockoutService.CheckSignIn(user, errors.New("token is invalid or has expired")); err != nil {
+		return nil, err
+	}
+	if err := checkPasswordResetRequired(user); err != nil {
+		return nil, err
+	}
+
+	if user.EmailVerifiedAt == nil {
+		if err := s.userRepo.MarkEmailVerified(user.ID); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	return user, nil
+}
+
+func hashDeviceSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,396 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+	"JwtSecurityImplementation/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+)
+
+const recoveryCodeCount = 10
+
+// ErrInvalidMFACode is returned when a TOTP or recovery code does not match
+var ErrInvalidMFACode = errors.New("invalid verification code")
+
+var errInvalidChallenge = errors.New("invalid or expired MFA challenge")
+
+// TOTPEnrollment holds what a user needs to add the account to an authenticator app
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+	QRCode []byte `json:"qr_code_png"`
+}
+
+// First factors a login can start from before its MFA challenge
+const (
+	FirstFactorPassword  = "password"
+	FirstFactorMagicLink = "magic_link"
+	FirstFactorEmailCode = "email_code"
+	FirstFactorOIDC      = "oidc"
+)
+
+// MFAChallenge is the pending second step of a login
+type MFAChallenge struct {
+	User        *models.User
+	RememberMe  bool
+	FirstFactor string
+
+	token     string
+	expiresAt time.Time
+}
+
+type MFAService struct {
+	userRepo       *repositories.UserRepository
+	mfaRepo        *repositories.MFARepository
+	tokenService   *TokenService
+	lockoutService *LockoutService
+	emailCodes     *EmailCodeService
+}
+
+func NewMFAService(userRepo *repositories.UserRepository, mfaRepo *repositories.MFARepository, tokenService *TokenService, lockoutService *LockoutService, emailCodes *EmailCodeService) *MFAService {
+	return &MFAService{
+		userRepo:       userRepo,
+		mfaRepo:        mfaRepo,
+		tokenService:   tokenService,
+		lockoutService: lockoutService,
+		emailCodes:     emailCodes,
+	}
+}
+
+// MFAStatus summarizes the second factor of a user
+type MFAStatus struct {
+	Enabled                bool  `json:"enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// Status reports whether MFA is enabled and how many recovery codes are left
+func (s *MFAService) Status(userID uint) (*MFAStatus, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining, err := s.mfaRepo.CountUnusedRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAStatus{Enabled: user.MFAEnabled, RecoveryCodesRemaining: remaining}, nil
+}
+
+// BeginTOTPEnrollment creates a pending TOTP secret. It only takes effect once confirmed with a code.
+func (s *MFAService) BeginTOTPEnrollment(userID uint) (*TOTPEnrollment, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := utils.SealTOTPSecret(secret, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.SetPendingTOTPSecret(user.ID, sealed); err != nil {
+		return nil, err
+	}
+
+	uri := utils.TOTPURI(config.GetEnv("MFA_ISSUER", "JwtSecurityImplementation"), user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render QR code: %w", err)
+	}
+
+	return &TOTPEnrollment{Secret: secret, URI: uri, QRCode: png}, nil
+}
+
+// ConfirmTOTPEnrollment enables MFA with the first code from the authenticator and
+// returns the one-time recovery codes, which are never shown again
+func (s *MFAService) ConfirmTOTPEnrollment(userID uint, code string) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("no pending authenticator enrollment")
+	}
+
+	secret, err := utils.OpenTOTPSecret(user.TOTPSecret, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now(), 1)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.EnableMFA(user.ID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA turns off MFA after re-checking the password and a current code
+func (s *MFAService) DisableMFA(userID uint, password, code string) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return errors.New("password is incorrect")
+	}
+	if err := s.verifyCode(user, code); err != nil {
+		return err
+	}
+
+	return s.mfaRepo.DisableMFA(user.ID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a current code
+func (s *MFAService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.MFAEnabled {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+	if err := s.verifyCode(user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// IssueChallenge returns a short-lived token that stands in for the token pair until
+// the second factor has been verified. firstFactor names how the user signed in so far.
+func (s *MFAService) IssueChallenge(user *models.User, rememberMe bool, firstFactor string) (string, error) {
+	ttl := time.Minute * time.Duration(config.GetEnvInt("MFA_CHALLENGE_TTL", 5))
+
+	claims := jwt.MapClaims{
+		"user_id":      user.ID,
+		"jti":          uuid.NewString(),
+		"remember_me":  rememberMe,
+		"first_factor": firstFactor,
+		"exp":          time.Now().Add(ttl).Unix(),
+		"token_type":   "mfa_challenge",
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(getJWTSecret()))
+}
+
+// ParseChallenge validates a challenge token without completing it
+func (s *MFAService) ParseChallenge(challengeToken string) (*MFAChallenge, error) {
+	_, claims, err := s.tokenService.ValidateToken(challengeToken)
+	if err != nil || claims["token_type"] != "mfa_challenge" {
+		return nil, errInvalidChallenge
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, errInvalidChallenge
+	}
+
+	user, err := s.userRepo.GetUserByID(uint(userID))
+	if err != nil {
+		return nil, errInvalidChallenge
+	}
+	if err := s.lockoutService.CheckSignIn(user, ErrInvalidMFACode); err != nil {
+		return nil, err
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return nil, errInvalidChallenge
+	}
+
+	rememberMe, _ := claims["remember_me"].(bool)
+	firstFactor, _ := claims["first_factor"].(string)
+	return &MFAChallenge{User: user, RememberMe: rememberMe, FirstFactor: firstFactor, token: challengeToken, expiresAt: expiresAt.Time}, nil
+}
+
+// CompleteChallenge burns a challenge once its second factor has been verified,
+// so it cannot start a second session, even from a concurrent request
+func (s *MFAService) CompleteChallenge(challenge *MFAChallenge) error {
+	if err := s.tokenService.ConsumeToken(challenge.token, challenge.expiresAt); err != nil {
+		if errors.Is(err, repositories.ErrTokenConsumed) {
+			return errInvalidChallenge
+		}
+		return err
+	}
+	return nil
+}
+
+// VerifyChallenge completes a login with a TOTP or recovery code.
+// A challenge can only be completed once; failed codes count towards the account lockout.
+func (s *MFAService) VerifyChallenge(challengeToken, code string, client models.ClientInfo) (*MFAChallenge, error) {
+	challenge, err := s.ParseChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.verifyCode(challenge.User, code)
+	if errors.Is(err, ErrInvalidMFACode) && challenge.allowsEmailCodes() {
+		if s.emailCodes.Verify(challenge.User.ID, EmailCodeMFA, code) == nil {
+			err = nil
+		}
+	}
+	if err != nil {
+		if lockErr := s.lockoutService.RecordFailure(challenge.User, client); lockErr != nil && !errors.Is(lockErr, ErrAccountLocked) {
+			log.Printf("Failed to record failed MFA code for user %d: %v", challenge.User.ID, lockErr)
+		}
+		return nil, err
+	}
+
+	if err := s.CompleteChallenge(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// SendChallengeEmailCode emails a code that can complete the challenge instead of the authenticator
+func (s *MFAService) SendChallengeEmailCode(challengeToken string) error {
+	challenge, err := s.ParseChallenge(challengeToken)
+	if err != nil {
+		return err
+	}
+	if !challenge.allowsEmailCodes() {
+		return errors.New("email codes cannot be used for two-factor authentication")
+	}
+	return s.emailCodes.Send(challenge.User, EmailCodeMFA)
+}
+
+// VerifyCode checks a TOTP or recovery code of a user with MFA enabled, e.g. for step-up
+func (s *MFAService) VerifyCode(userID uint, code string) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	return s.verifyCode(user, code)
+}
+
+// verifyCode accepts a current TOTP code, or else an unused recovery code
+func (s *MFAService) verifyCode(user *models.User, code string) error {
+	// Without a secret every code would be derived from an empty key, e.g. for a challenge
+	// issued before MFA was disabled
+	if !user.MFAEnabled || user.TOTPSecret == "" {
+		return ErrInvalidMFACode
+	}
+	code = strings.TrimSpace(code)
+
+	secret, err := utils.OpenTOTPSecret(user.TOTPSecret, user.ID)
+	if err != nil {
+		return err
+	}
+	if step, ok := utils.ValidateTOTP(secret, code, time.Now(), 1); ok {
+		fresh, err := s.mfaRepo.UseTOTPStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return errors.New("verification code has already been used")
+		}
+		s.rekeyTOTPSecret(user, secret)
+		return nil
+	}
+
+	used, err := s.mfaRepo.ConsumeRecoveryCode(user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// rekeyTOTPSecret moves a secret stored in plaintext or under an older key to the current key.
+// Failures only delay the migration to the next verified code.
+func (s *MFAService) rekeyTOTPSecret(user *models.User, secret string) {
+	if !utils.TOTPSecretNeedsRekey(user.TOTPSecret) {
+		return
+	}
+
+	sealed, err := utils.SealTOTPSecret(secret, user.ID)
+	if err == nil {
+		err = s.mfaRepo.UpdateTOTPSecret(user.ID, sealed)
+	}
+	if err != nil {
+		log.Printf("Failed to re-encrypt TOTP secret of user %d: %v", user.ID, err)
+	}
+}
+
+// generateRecoveryCodes returns the codes to show the user and the hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		random := make([]byte, 10)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+
+		// 16 base32 characters, grouped for readability
+		encoded := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(random))
+		code := encoded[:8] + "-" + encoded[8:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode normalizes a code and hashes it; the codes are random enough for SHA-256
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// allowsEmailCodes reports whether an emailed code may stand in for the authenticator.
+// A login that already proved control of the mailbox cannot use it again as its second factor.
+func (c *MFAChallenge) allowsEmailCodes() bool {
+	if c.FirstFactor == FirstFactorMagicLink || c.FirstFactor == FirstFactorEmailCode {
+		return false
+	}
+	return config.GetEnvBool("MFA_EMAIL_CODES", false)
+}
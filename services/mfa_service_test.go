@@ -0,0 +1,112 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+)
+
+func TestMFAChallengeCanOnlyBeCompletedOnce(t *testing.T) {
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	user := createTestUser(t, userRepo, &models.User{FirstName: "Jane", Email: "jane@example.com", MFAEnabled: true})
+
+	const attempts = 4
+	lockstep := &lockstepTokenStore{TokenStore: repositories.NewMemoryTokenStore()}
+	lockstep.checked.Add(attempts)
+	service := NewMFAService(userRepo, repositories.NewMFARepository(db), NewTokenService(lockstep, userRepo, nil), newTestLockoutService(db, userRepo), nil)
+
+	challengeToken, err := service.IssueChallenge(user, false, FirstFactorPassword)
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	completed := 0
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			challenge, err := service.ParseChallenge(challengeToken)
+			if err != nil {
+				return
+			}
+			if service.CompleteChallenge(challenge) == nil {
+				mu.Lock()
+				completed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if completed != 1 {
+		t.Errorf("challenge completed %d times by concurrent requests, want once", completed)
+	}
+}
+
+// totpCode computes the current six-digit RFC 6238 code for a raw key
+func totpCode(key []byte, t time.Time) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/30))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:])&0x7fffffff)%1000000)
+}
+
+func TestMFAChallengeRefusedAfterMFAIsDisabled(t *testing.T) {
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	mfaRepo := repositories.NewMFARepository(db)
+	user := createTestUser(t, userRepo, &models.User{FirstName: "Jane", Email: "jane@example.com", MFAEnabled: true, TOTPSecret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"})
+	service := NewMFAService(userRepo, mfaRepo, NewTokenService(repositories.NewMemoryTokenStore(), userRepo, nil), newTestLockoutService(db, userRepo), nil)
+
+	challengeToken, err := service.IssueChallenge(user, false, FirstFactorPassword)
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+	if err := mfaRepo.DisableMFA(user.ID); err != nil {
+		t.Fatalf("DisableMFA: %v", err)
+	}
+
+	// The code an empty key yields must not complete the old challenge
+	if _, err := service.VerifyChallenge(challengeToken, totpCode(nil, time.Now()), testClient); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("VerifyChallenge after MFA was disabled returned %v, want ErrInvalidMFACode", err)
+	}
+	if err := service.VerifyCode(user.ID, totpCode(nil, time.Now())); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("VerifyCode after MFA was disabled returned %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestMFACodeCannotBeReplayed(t *testing.T) {
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	user := createTestUser(t, userRepo, &models.User{FirstName: "Jane", Email: "jane@example.com", MFAEnabled: true, TOTPSecret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"})
+	service := NewMFAService(userRepo, repositories.NewMFARepository(db), nil, newTestLockoutService(db, userRepo), nil)
+
+	code := totpCode([]byte("12345678901234567890"), time.Now())
+	if err := service.VerifyCode(user.ID, code); err != nil {
+		t.Fatalf("VerifyCode: %v", err)
+	}
+	if err := service.VerifyCode(user.ID, code); err == nil {
+		t.Error("the same code was accepted twice")
+	}
+
+	// A code from an earlier step is refused once a later one was used
+	if err := service.VerifyCode(user.ID, totpCode([]byte("12345678901234567890"), time.Now().Add(-30*time.Second))); err == nil {
+		t.Error("a code older than the last used one was accepted")
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+const oidcFlowTTL = 10 * time.Minute
+
+var errInvalidOIDCFlow = errors.New("sign-in with the identity provider failed or has expired, please try again")
+
+// OIDCLogin is the outcome of a completed sign-in with an upstream provider
+type OIDCLogin struct {
+	User       *models.User
+	RememberMe bool
+}
+
+// oidcProvider is an upstream OpenID Connect provider configured through OIDC_<NAME>_* variables.
+// Discovery runs on first use, and is retried until it succeeds, so an unreachable
+// provider does not stop the server.
+type oidcProvider struct {
+	name         string
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+
+	mu         sync.Mutex
+	discovered bool
+	oauth2     oauth2.Config
+	verifier   *oidc.IDTokenVerifier
+}
+
+// OIDCService signs users in through upstream OpenID Connect providers using the
+// authorization code flow with PKCE, then links or provisions a local user
+type OIDCService struct {
+	userRepo       *repositories.UserRepository
+	identityRepo   *repositories.ExternalIdentityRepository
+	lockoutService *LockoutService
+	providers      map[string]*oidcProvider
+}
+
+func NewOIDCService(userRepo *repositories.UserRepository, identityRepo *repositories.ExternalIdentityRepository, lockoutService *LockoutService) *OIDCService {
+	providers := map[string]*oidcProvider{}
+	for _, name := range strings.Split(config.GetEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers[name] = &oidcProvider{
+			name:         name,
+			issuer:       config.GetEnv(prefix+"ISSUER", ""),
+			clientID:     config.GetEnv(prefix+"CLIENT_ID", ""),
+			clientSecret: config.GetEnv(prefix+"CLIENT_SECRET", ""),
+			redirectURL:  config.GetEnv(prefix+"REDIRECT_URL", config.GetEnv("APP_BASE_URL", "http://localhost:8080")+"/auth/oidc/"+name+"/callback"),
+			scopes:       strings.Fields(config.GetEnv(prefix+"SCOPES", "openid email profile")),
+		}
+	}
+
+	return &OIDCService{
+		userRepo:       userRepo,
+		identityRepo:   identityRepo,
+		lockoutService: lockoutService,
+		providers:      providers,
+	}
+}
+
+// Providers returns the names of the configured providers
+func (s *OIDCService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// BeginLogin returns the provider's authorization URL and the signed flow state that must
+// come back with the callback, normally kept in a short-lived cookie
+func (s *OIDCService) BeginLogin(ctx context.Context, providerName string, rememberMe bool) (string, string, error) {
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	claims := jwt.MapClaims{
+		"provider":    provider.name,
+		"state":       state,
+		"nonce":       nonce,
+		"verifier":    verifier,
+		"remember_me": rememberMe,
+		"exp":         time.Now().Add(oidcFlowTTL).Unix(),
+		"token_type":  "oidc_flow",
+	}
+	flow, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(getJWTSecret()))
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL := provider.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	return authURL, flow, nil
+}
+
+// FinishLogin exchanges the authorization code, validates the ID token against the provider's
+// keys and returns the linked or newly provisioned user
+func (s *OIDCService) FinishLogin(ctx context.Context, providerName, flow, state, code string) (*OIDCLogin, error) {
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(flow, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(getJWTSecret()), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || claims["token_type"] != "oidc_flow" || claims["provider"] != provider.name {
+		return nil, errInvalidOIDCFlow
+	}
+	if expected, _ := claims["state"].(string); expected == "" || expected != state {
+		return nil, errInvalidOIDCFlow
+	}
+	verifier, _ := claims["verifier"].(string)
+
+	token, err := provider.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("identity provider did not return an ID token")
+	}
+
+	idToken, err := provider.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if nonce, _ := claims["nonce"].(string); nonce == "" || idToken.Nonce != nonce {
+		return nil, errors.New("invalid ID token: nonce mismatch")
+	}
+
+	var profile struct {
+		Email         string      `json:"email"`
+		EmailVerified interface{} `json:"email_verified"`
+		GivenName     string      `json:"given_name"`
+		FamilyName    string      `json:"family_name"`
+		Name          string      `json:"name"`
+	}
+	if err := idToken.Claims(&profile); err != nil {
+		return nil, fmt.Errorf("invalid ID token claims: %w", err)
+	}
+
+	// Some providers send email_verified as a string
+	emailVerified := profile.EmailVerified == true || profile.EmailVerified == "true"
+
+	user, err := s.resolveUser(provider.name, idToken.Subject, strings.TrimSpace(profile.Email), emailVerified, profile.GivenName, profile.FamilyName, profile.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lockoutService.CheckSignIn(user, ErrInvalidCredentials); err != nil {
+		return nil, err
+	}
+	if err := checkPasswordResetRequired(user); err != nil {
+		return nil, err
+	}
+
+	rememberMe, _ := claims["remember_me"].(bool)
+	return &OIDCLogin{User: user, RememberMe: rememberMe}, nil
+}
+
+// ListIdentities returns the upstream identities linked to a user
+func (s *OIDCService) ListIdentities(userID uint) ([]models.ExternalIdentity, error) {
+	return s.identityRepo.ListByUser(userID)
+}
+
+// UnlinkIdentity removes a linked identity, unless it is the user's only way to sign in
+func (s *OIDCService) UnlinkIdentity(userID, id uint) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Password == "" {
+		identities, err := s.identityRepo.ListByUser(userID)
+		if err != nil {
+			return err
+		}
+		if len(identities) <= 1 {
+			return errors.New("set a password before unlinking your last identity provider")
+		}
+	}
+
+	return s.identityRepo.Delete(userID, id)
+}
+
+// resolveUser finds the user linked to an upstream account. Unknown accounts are linked to an
+// existing user only when both the provider and this service have verified the email,
+// otherwise a user is provisioned.
+func (s *OIDCService) resolveUser(provider, subject, email string, emailVerified bool, givenName, familyName, name string) (*models.User, error) {
+	if identity, err := s.identityRepo.FindBySubject(provider, subject); err == nil {
+		if err := s.identityRepo.TouchLogin(identity.ID, email); err != nil {
+			return nil, err
+		}
+		return s.userRepo.GetUserByID(identity.UserID)
+	}
+
+	if email == "" {
+		return nil, errors.New("identity provider did not share an email address")
+	}
+
+	user, err := s.userRepo.FindUserByEmail(email)
+	if err == nil {
+		// Linking on an unverified email would let anyone take over the local account, and linking to
+		// an unverified local account would keep the password of whoever registered the address first
+		if !emailVerified || user.EmailVerifiedAt == nil {
+			return nil, errors.New("an account with this email already exists, sign in to it first")
+		}
+	} else {
+		if !config.GetEnvBool("OIDC_AUTO_PROVISION", true) {
+			return nil, errors.New("no account exists for this identity")
+		}
+		if user, err = s.provisionUser(email, emailVerified, givenName, familyName, name); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	identity := &models.ExternalIdentity{
+		UserID:      user.ID,
+		Provider:    provider,
+		Subject:     subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}
+	if err := s.identityRepo.Create(identity); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// provisionUser creates a local user without a password for a new upstream account
+func (s *OIDCService) provisionUser(email string, emailVerified bool, givenName, familyName, name string) (*models.User, error) {
+	if givenName == "" && familyName == "" {
+		givenName, familyName, _ = strings.Cut(strings.TrimSpace(name), " ")
+	}
+	if givenName == "" {
+		givenName, _, _ = strings.Cut(email, "@")
+	}
+
+	user := &models.User{
+		FirstName: givenName,
+		LastName:  familyName,
+		Email:     email,
+	}
+	if emailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	return s.userRepo.CreateUser(user)
+}
+
+// provider returns a configured provider, running discovery on first use
+func (s *OIDCService) provider(ctx context.Context, name string) (*oidcProvider, error) {
+	provider, ok := s.providers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown identity provider %q", name)
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if provider.discovered {
+		return provider, nil
+	}
+
+	discoveryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	discovered, err := oidc.NewProvider(discoveryCtx, provider.issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover identity provider %q: %w", provider.name, err)
+	}
+
+	provider.oauth2 = oauth2.Config{
+		ClientID:     provider.clientID,
+		ClientSecret: provider.clientSecret,
+		RedirectURL:  provider.redirectURL,
+		Endpoint:     discovered.Endpoint(),
+		Scopes:       provider.scopes,
+	}
+	provider.verifier = discovered.Verifier(&oidc.Config{ClientID: provider.clientID})
+	provider.discovered = true
+
+	return provider, nil
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockOIDCProvider serves discovery, JWKS and a token endpoint that checks PKCE like a real provider
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockAuthorization
+}
+
+// mockAuthorization is what the provider remembers about an authorization code
+type mockAuthorization struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	provider := &mockOIDCProvider{key: key, codes: map[string]mockAuthorization{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", provider.discovery)
+	mux.HandleFunc("/jwks", provider.jwks)
+	mux.HandleFunc("/token", provider.token)
+	provider.server = httptest.NewServer(mux)
+	t.Cleanup(provider.server.Close)
+
+	return provider
+}
+
+func (p *mockOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                p.server.URL,
+		"authorization_endpoint":                p.server.URL + "/authorize",
+		"token_endpoint":                        p.server.URL + "/token",
+		"jwks_uri":                              p.server.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *mockOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	authorization, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != authorization.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, authorization.claims)
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+// authorize plays the user approving the login at the provider: it reads the PKCE challenge,
+// state and nonce from the authorization URL and returns a code and state for the callback
+func (p *mockOIDCProvider) authorize(t *testing.T, authURL string, claims jwt.MapClaims) (string, string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization URL lacks a PKCE challenge: %s", authURL)
+	}
+
+	idClaims := jwt.MapClaims{
+		"iss":   p.server.URL,
+		"aud":   "test-client",
+		"nonce": query.Get("nonce"),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	code := randomTestToken(t)
+	p.mu.Lock()
+	p.codes[code] = mockAuthorization{challenge: query.Get("code_challenge"), claims: idClaims}
+	p.mu.Unlock()
+
+	return code, query.Get("state")
+}
+
+func randomTestToken(t *testing.T) string {
+	token, err := randomToken()
+	if err != nil {
+		t.Fatalf("random token: %v", err)
+	}
+	return token
+}
+
+type oidcTestEnv struct {
+	provider     *mockOIDCProvider
+	service      *OIDCService
+	userRepo     *repositories.UserRepository
+	identityRepo *repositories.ExternalIdentityRepository
+}
+
+func newOIDCTestEnv(t *testing.T) *oidcTestEnv {
+	provider := newMockOIDCProvider(t)
+	t.Setenv("OIDC_PROVIDERS", "mock")
+	t.Setenv("OIDC_MOCK_ISSUER", provider.server.URL)
+	t.Setenv("OIDC_MOCK_CLIENT_ID", "test-client")
+	t.Setenv("OIDC_MOCK_CLIENT_SECRET", "test-secret")
+	t.Setenv("OIDC_AUTO_PROVISION", "true")
+
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	identityRepo := repositories.NewExternalIdentityRepository(db)
+
+	return &oidcTestEnv{
+		provider:     provider,
+		service:      NewOIDCService(userRepo, identityRepo, newTestLockoutService(db, userRepo)),
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+	}
+}
+
+// login runs a complete sign-in in which the provider asserts the given claims
+func (e *oidcTestEnv) login(t *testing.T, claims jwt.MapClaims) (*OIDCLogin, error) {
+	t.Helper()
+
+	authURL, flow, err := e.service.BeginLogin(context.Background(), "mock", false)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	code, state := e.provider.authorize(t, authURL, claims)
+	return e.service.FinishLogin(context.Background(), "mock", flow, state, code)
+}
+
+func TestOIDCProvisionsNewUsers(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	login, err := env.login(t, jwt.MapClaims{"sub": "subject-1", "email": "new@example.com", "email_verified": true, "name": "New User"})
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if login.User.Email != "new@example.com" || login.User.EmailVerifiedAt == nil || login.User.FirstName != "New" {
+		t.Errorf("provisioned user = %+v, want a verified new@example.com named New", login.User)
+	}
+
+	// The next login finds the user through the linked subject, whatever the email says
+	again, err := env.login(t, jwt.MapClaims{"sub": "subject-1", "email": "renamed@example.com", "email_verified": true})
+	if err != nil {
+		t.Fatalf("second FinishLogin: %v", err)
+	}
+	if again.User.ID != login.User.ID {
+		t.Errorf("second login returned user %d, want %d", again.User.ID, login.User.ID)
+	}
+}
+
+func TestOIDCLinksVerifiedAccounts(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	now := time.Now()
+	local := createTestUser(t, env.userRepo, &models.User{FirstName: "Local", Email: "local@example.com", EmailVerifiedAt: &now})
+
+	login, err := env.login(t, jwt.MapClaims{"sub": "subject-2", "email": "local@example.com", "email_verified": "true"})
+	if err != nil {
+		t.Fatalf("FinishLogin: %v", err)
+	}
+	if login.User.ID != local.ID {
+		t.Errorf("login returned user %d, want the existing user %d", login.User.ID, local.ID)
+	}
+	if _, err := env.identityRepo.FindBySubject("mock", "subject-2"); err != nil {
+		t.Errorf("identity was not linked: %v", err)
+	}
+}
+
+func TestOIDCRefusesToLinkUnverifiedEmails(t *testing.T) {
+	now := time.Now()
+
+	for name, test := range map[string]struct {
+		localVerifiedAt *time.Time
+		emailVerified   interface{}
+	}{
+		"provider has not verified the email": {localVerifiedAt: &now, emailVerified: false},
+		"local account has not verified it":   {localVerifiedAt: nil, emailVerified: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			env := newOIDCTestEnv(t)
+			createTestUser(t, env.userRepo, &models.User{FirstName: "Local", Email: "victim@example.com", EmailVerifiedAt: test.localVerifiedAt})
+
+			if _, err := env.login(t, jwt.MapClaims{"sub": "attacker", "email": "victim@example.com", "email_verified": test.emailVerified}); err == nil {
+				t.Fatal("FinishLogin linked the identity")
+			}
+			if _, err := env.identityRepo.FindBySubject("mock", "attacker"); err == nil {
+				t.Error("identity was stored although linking was refused")
+			}
+		})
+	}
+}
+
+func TestOIDCRejectsStateMismatch(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	authURL, flow, err := env.service.BeginLogin(context.Background(), "mock", false)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	code, _ := env.provider.authorize(t, authURL, jwt.MapClaims{"sub": "subject-3", "email": "state@example.com", "email_verified": true})
+
+	if _, err := env.service.FinishLogin(context.Background(), "mock", flow, "forged-state", code); err != errInvalidOIDCFlow {
+		t.Errorf("FinishLogin with a forged state returned %v, want errInvalidOIDCFlow", err)
+	}
+}
+
+func TestOIDCRejectsNonceMismatch(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	_, err := env.login(t, jwt.MapClaims{"sub": "subject-4", "email": "nonce@example.com", "email_verified": true, "nonce": "replayed-nonce"})
+	if err == nil || !strings.Contains(err.Error(), "nonce mismatch") {
+		t.Fatalf("FinishLogin with another login's nonce returned %v, want a nonce mismatch", err)
+	}
+	if _, err := env.userRepo.FindUserByEmail("nonce@example.com"); err == nil {
+		t.Error("a user was provisioned from the rejected ID token")
+	}
+}
+
+func TestOIDCRequiresThePKCEVerifierOfTheFlow(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	// The code was issued to the first flow; the second flow has a different verifier
+	firstURL, _, err := env.service.BeginLogin(context.Background(), "mock", false)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	code, _ := env.provider.authorize(t, firstURL, jwt.MapClaims{"sub": "subject-5", "email": "pkce@example.com", "email_verified": true})
+
+	secondURL, secondFlow, err := env.service.BeginLogin(context.Background(), "mock", false)
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	parsed, _ := url.Parse(secondURL)
+
+	_, err = env.service.FinishLogin(context.Background(), "mock", secondFlow, parsed.Query().Get("state"), code)
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("FinishLogin with another flow's PKCE verifier returned %v, want invalid_grant", err)
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// OneTimeTokenService issues signed, single-use tokens for links sent by email
+type OneTimeTokenService struct {
+	repo *repositories.OneTimeTokenRepository
+}
+
+func NewOneTimeTokenService(repo *repositories.OneTimeTokenRepository) *OneTimeTokenService {
+	return &OneTimeTokenService{repo: repo}
+}
+
+// Issue creates a token for the given purpose, superseding any outstanding token of that purpose
+func (s *OneTimeTokenService) Issue(userID uint, purpose string, ttl time.Duration) (string, error) {
+	return s.IssueBound(userID, purpose, ttl, "")
+}
+
+// IssueBound is like Issue, but the token can only be consumed together with the given
+// binding, e.g. a hash of a secret kept by the device that requested it
+func (s *OneTimeTokenService) IssueBound(userID uint, purpose string, ttl time.Duration, binding string) (string, error) {
+	if err := s.repo.InvalidateForUser(userID, purpose); err != nil {
+		return "", err
+	}
+
+	record := &models.OneTimeToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Purpose:   purpose,
+		Binding:   binding,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.repo.Create(record); err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"user_id":    userID,
+		"jti":        record.ID,
+		"purpose":    purpose,
+		"exp":        record.ExpiresAt.Unix(),
+		"token_type": "one_time",
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(getJWTSecret()))
+}
+
+// Consume verifies a token for the given purpose and marks it as used
+func (s *OneTimeTokenService) Consume(tokenString, purpose string) (*models.OneTimeToken, error) {
+	return s.ConsumeBound(tokenString, purpose, "")
+}
+
+// ConsumeBound verifies a token issued with IssueBound and marks it as used
+func (s *OneTimeTokenService) ConsumeBound(tokenString, purpose, binding string) (*models.OneTimeToken, error) {
+	claims, err := s.parse(tokenString, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenID, ok := claims["jti"].(string)
+	if !ok {
+		return nil, errors.New("token is invalid or has expired")
+	}
+
+	return s.repo.Consume(tokenID, purpose, binding)
+}
+
+// Peek returns the user a token for the given purpose was issued to without using it up,
+// so the request can be checked before the token is consumed. Only Consume proves the token is unused.
+func (s *OneTimeTokenService) Peek(tokenString, purpose string) (uint, error) {
+	claims, err := s.parse(tokenString, purpose)
+	if err != nil {
+		return 0, err
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, errors.New("token is invalid or has expired")
+	}
+	return uint(userID), nil
+}
+
+// parse verifies the signature, expiry and purpose of a token
+func (s *OneTimeTokenService) parse(tokenString, purpose string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(getJWTSecret()), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, errors.New("token is invalid or has expired")
+	}
+
+	if claims["token_type"] != "one_time" || claims["purpose"] != purpose {
+		return nil, errors.New("token is invalid or has expired")
+	}
+	return claims, nil
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+)
+
+var errInvalidCeremony = errors.New("invalid or expired passkey ceremony")
+
+// PasskeyLogin is the outcome of a successful passkey login ceremony
+type PasskeyLogin struct {
+	User       *models.User
+	RememberMe bool
+}
+
+// PasskeyService registers WebAuthn credentials and signs users in with them.
+// The ceremony state travels in a signed, single-use token so no server-side storage is needed.
+type PasskeyService struct {
+	webAuthn       *webauthn.WebAuthn
+	userRepo       *repositories.UserRepository
+	credentialRepo *repositories.WebAuthnCredentialRepository
+	tokenService   *TokenService
+	mfaService     *MFAService
+	lockoutService *LockoutService
+}
+
+func NewPasskeyService(webAuthn *webauthn.WebAuthn, userRepo *repositories.UserRepository, credentialRepo *repositories.WebAuthnCredentialRepository, tokenService *TokenService, mfaService *MFAService, lockoutService *LockoutService) *PasskeyService {
+	return &PasskeyService{
+		webAuthn:       webAuthn,
+		userRepo:       userRepo,
+		credentialRepo: credentialRepo,
+		tokenService:   tokenService,
+		mfaService:     mfaService,
+		lockoutService: lockoutService,
+	}
+}
+
+// ListPasskeys returns the credentials registered by a user
+func (s *PasskeyService) ListPasskeys(userID uint) ([]models.WebAuthnCredential, error) {
+	return s.credentialRepo.ListByUser(userID)
+}
+
+// RenamePasskey changes the display name of one of the user's credentials
+func (s *PasskeyService) RenamePasskey(userID, id uint, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("name cannot be empty")
+	}
+	return s.credentialRepo.Rename(userID, id, name)
+}
+
+// DeletePasskey removes one of the user's credentials
+func (s *PasskeyService) DeletePasskey(userID, id uint) error {
+	return s.credentialRepo.Delete(userID, id)
+}
+
+// BeginRegistration returns the creation options for the browser and the ceremony token
+func (s *PasskeyService) BeginRegistration(userID uint) (*protocol.CredentialCreation, string, error) {
+	user, err := s.loadWebAuthnUser(userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ceremony, err := s.signCeremony(ceremonyRegistration, session, jwt.MapClaims{"user_id": userID})
+	if err != nil {
+		return nil, "", err
+	}
+	return creation, ceremony, nil
+}
+
+// FinishRegistration verifies the attestation returned by the authenticator and stores the credential
+func (s *PasskeyService) FinishRegistration(userID uint, ceremony, name string, response json.RawMessage) (*models.WebAuthnCredential, error) {
+	session, claims, err := s.consumeCeremony(ceremony, ceremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if ceremonyUserID, ok := claims["user_id"].(float64); !ok || uint(ceremonyUserID) != userID {
+		return nil, errInvalidCeremony
+	}
+
+	user, err := s.loadWebAuthnUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential: %w", err)
+	}
+
+	credential, err := s.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("credential verification failed: %w", err)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	record := &models.WebAuthnCredential{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		Transports:      transports,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+	if err := s.credentialRepo.Create(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// BeginLogin returns the assertion options and the ceremony token for a login.
+// With an MFA challenge the passkey completes a password login as the second factor.
+// Otherwise the login is passwordless and always asks for a discoverable credential,
+// so the options look the same for every caller and reveal nothing about accounts.
+func (s *PasskeyService) BeginLogin(mfaToken string, rememberMe bool) (*protocol.CredentialAssertion, string, error) {
+	if mfaToken != "" {
+		challenge, err := s.mfaService.ParseChallenge(mfaToken)
+		if err != nil {
+			return nil, "", err
+		}
+		user, err := s.loadWebAuthnUser(challenge.User.ID)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(user.credentials) == 0 {
+			return nil, "", errors.New("no passkeys registered")
+		}
+
+		assertion, session, err := s.webAuthn.BeginLogin(user)
+		if err != nil {
+			return nil, "", err
+		}
+		ceremony, err := s.signCeremony(ceremonyLogin, session, jwt.MapClaims{"mfa_token": mfaToken})
+		return assertion, ceremony, err
+	}
+
+	// Passwordless logins rely on the authenticator to verify the user
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, "", err
+	}
+
+	ceremony, err := s.signCeremony(ceremonyLogin, session, jwt.MapClaims{"remember_me": rememberMe})
+	return assertion, ceremony, err
+}
+
+// FinishLogin verifies the assertion, checks the sign counter and returns the signed-in user
+func (s *PasskeyService) FinishLogin(ceremony string, response json.RawMessage) (*PasskeyLogin, error) {
+	session, claims, err := s.consumeCeremony(ceremony, ceremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential: %w", err)
+	}
+
+	var challenge *MFAChallenge
+	if mfaToken, _ := claims["mfa_token"].(string); mfaToken != "" {
+		if challenge, err = s.mfaService.ParseChallenge(mfaToken); err != nil {
+			return nil, err
+		}
+	}
+
+	var user *webAuthnUser
+	var credential *webauthn.Credential
+	if len(session.UserID) > 0 {
+		if user, err = s.userFromHandle(session.UserID); err != nil {
+			return nil, errors.New("passkey verification failed")
+		}
+		credential, err = s.webAuthn.ValidateLogin(user, *session, parsed)
+	} else {
+		credential, err = s.webAuthn.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+			user, err = s.userFromHandle(userHandle)
+			return user, err
+		}, *session, parsed)
+	}
+	if err != nil {
+		return nil, errors.New("passkey verification failed")
+	}
+	if challenge != nil && challenge.User.ID != user.user.ID {
+		return nil, errors.New("passkey verification failed")
+	}
+
+	// A counter that went backwards means the key material may have been cloned
+	record, err := s.credentialRepo.FindByCredentialID(base64.RawURLEncoding.EncodeToString(credential.ID))
+	if err != nil || record.UserID != user.user.ID {
+		return nil, errors.New("passkey verification failed")
+	}
+	if err := s.credentialRepo.RecordUse(record.ID, credential.Authenticator.SignCount, credential.Flags.BackupState, credential.Authenticator.CloneWarning); err != nil {
+		return nil, err
+	}
+	if credential.Authenticator.CloneWarning {
+		return nil, errors.New("passkey sign counter did not increase, the authenticator may be cloned")
+	}
+
+	if challenge != nil {
+		if err := s.mfaService.CompleteChallenge(challenge); err != nil {
+			return nil, err
+		}
+		return &PasskeyLogin{User: challenge.User, RememberMe: challenge.RememberMe}, nil
+	}
+
+	if err := s.lockoutService.CheckSignIn(user.user, errors.New("passkey verification failed")); err != nil {
+		return nil, err
+	}
+	if err := checkPasswordResetRequired(user.user); err != nil {
+		return nil, err
+	}
+	if user.user.EmailVerifiedAt == nil && EmailVerificationPolicy() == EmailVerificationBlock {
+		return nil, ErrEmailNotVerified
+	}
+
+	rememberMe, _ := claims["remember_me"].(bool)
+	return &PasskeyLogin{User: user.user, RememberMe: rememberMe}, nil
+}
+
+// signCeremony packs the WebAuthn session data into a short-lived token
+func (s *PasskeyService) signCeremony(kind string, session *webauthn.SessionData, extra jwt.MapClaims) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := session.Expires
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(5 * time.Minute)
+	}
+
+	claims := jwt.MapClaims{
+		"jti":        uuid.NewString(),
+		"ceremony":   kind,
+		"session":    string(data),
+		"exp":        expiresAt.Unix(),
+		"token_type": "webauthn_ceremony",
+	}
+	for key, value := range extra {
+		claims[key] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(getJWTSecret()))
+}
+
+// consumeCeremony validates a ceremony token and burns it so every challenge is answered once
+func (s *PasskeyService) consumeCeremony(ceremony, kind string) (*webauthn.SessionData, jwt.MapClaims, error) {
+	_, claims, err := s.tokenService.ValidateToken(ceremony)
+	if err != nil || claims["token_type"] != "webauthn_ceremony" || claims["ceremony"] != kind {
+		return nil, nil, errInvalidCeremony
+	}
+
+	data, _ := claims["session"].(string)
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return nil, nil, errInvalidCeremony
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return nil, nil, errInvalidCeremony
+	}
+	// Concurrent requests with the same ceremony race here, only one of them gets to consume it
+	if err := s.tokenService.ConsumeToken(ceremony, expiresAt.Time); err != nil {
+		if errors.Is(err, repositories.ErrTokenConsumed) {
+			return nil, nil, errInvalidCeremony
+		}
+		return nil, nil, err
+	}
+
+	return &session, claims, nil
+}
+
+// userFromHandle resolves the user handle stored in a credential back to the user
+func (s *PasskeyService) userFromHandle(userHandle []byte) (*webAuthnUser, error) {
+	userID, err := strconv.ParseUint(string(userHandle), 10, 64)
+	if err != nil {
+		return nil, errors.New("unknown user handle")
+	}
+	return s.loadWebAuthnUser(uint(userID))
+}
+
+func (s *PasskeyService) loadWebAuthnUser(userID uint) (*webAuthnUser, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.withCredentials(user)
+}
+
+func (s *PasskeyService) withCredentials(user *models.User) (*webAuthnUser, error) {
+	records, err := s.credentialRepo.ListByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(records))
+	for _, record := range records {
+		id, err := base64.RawURLEncoding.DecodeString(record.CredentialID)
+		if err != nil {
+			continue
+		}
+
+		transports := make([]protocol.AuthenticatorTransport, 0, len(record.Transports))
+		for _, transport := range record.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              id,
+			PublicKey:       record.PublicKey,
+			AttestationType: record.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				UserPresent:    true,
+				BackupEligible: record.BackupEligible,
+				BackupState:    record.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:       record.AAGUID,
+				SignCount:    record.SignCount,
+				CloneWarning: record.CloneWarning,
+			},
+		})
+	}
+
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// webAuthnUser adapts models.User to the webauthn.User interface
+type webAuthnUser struct {
+	user        *models.User
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.FormatUint(uint64(u.user.ID), 10))
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return strings.TrimSpace(u.user.FirstName + " " + u.user.LastName)
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"JwtSecurityImplementation/repositories"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+func TestPasskeyCeremonyCanOnlyBeConsumedOnce(t *testing.T) {
+	const attempts = 4
+	lockstep := &lockstepTokenStore{TokenStore: repositories.NewMemoryTokenStore()}
+	lockstep.checked.Add(attempts)
+	service := NewPasskeyService(nil, nil, nil, NewTokenService(lockstep, nil, nil), nil, nil)
+
+	ceremony, err := service.signCeremony(ceremonyLogin, &webauthn.SessionData{
+		Challenge: "challenge",
+		Expires:   time.Now().Add(time.Minute),
+	}, nil)
+	if err != nil {
+		t.Fatalf("signCeremony: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	consumed := 0
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := service.consumeCeremony(ceremony, ceremonyLogin); err == nil {
+				mu.Lock()
+				consumed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if consumed != 1 {
+		t.Errorf("ceremony consumed %d times by concurrent requests, want once", consumed)
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/pwned"
+	"JwtSecurityImplementation/repositories"
+	"JwtSecurityImplementation/utils"
+)
+
+var (
+	// ErrPasswordReused is returned when a new password matches the current or a recent one
+	ErrPasswordReused = errors.New("password has been used recently, choose a different one")
+	// ErrPasswordExpired is returned at login when the password is older than PASSWORD_MAX_AGE
+	ErrPasswordExpired = errors.New("password has expired, reset it to sign in")
+	// ErrPasswordResetRequired is returned by logins without a password while an administrator requires a new one
+	ErrPasswordResetRequired = errors.New("a password reset is required, use the emailed link to choose a new password")
+)
+
+// PasswordPolicyError lists every rule a new password breaks
+type PasswordPolicyError struct {
+	Violations []string
+	reused     bool
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return strings.Join(e.Violations, "; ")
+}
+
+// Is lets callers keep matching ErrPasswordReused when reuse is one of several violations
+func (e *PasswordPolicyError) Is(target error) bool {
+	return target == ErrPasswordReused && e.reused
+}
+
+// PasswordPolicy checks new passwords against the configured rules, the offline breach
+// corpus and the user's recent passwords
+type PasswordPolicy struct {
+	historyRepo *repositories.PasswordHistoryRepository
+	breached    pwned.Checker
+}
+
+// NewPasswordPolicy creates the policy; breached may be nil when no breach corpus is configured
+func NewPasswordPolicy(historyRepo *repositories.PasswordHistoryRepository, breached pwned.Checker) *PasswordPolicy {
+	return &PasswordPolicy{historyRepo: historyRepo, breached: breached}
+}
+
+// Check returns a *PasswordPolicyError listing every violation, or nil if the password is acceptable.
+// Users that are not stored yet have no history to compare against.
+func (p *PasswordPolicy) Check(user *models.User, password string) error {
+	violations := utils.LoadPasswordRules().Violations(password, user.FirstName, user.LastName, user.Email)
+
+	if p.breached != nil {
+		// An unreadable corpus should not stop users from setting passwords
+		breached, err := p.breached.Breached(password)
+		if err != nil {
+			log.Printf("Failed to check password against breach corpus: %v", err)
+		} else if breached {
+			violations = append(violations, "password has appeared in a data breach, choose a different one")
+		}
+	}
+
+	reused := false
+	if user.ID != 0 {
+		var err error
+		if reused, err = p.isReused(user, password); err != nil {
+			return err
+		}
+		if reused {
+			violations = append(violations, ErrPasswordReused.Error())
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations, reused: reused}
+	}
+	return nil
+}
+
+// checkPasswordResetRequired refuses logins that skip the password while an administrator
+// requires a new one, so a forced reset cannot be sidestepped with another login method
+func checkPasswordResetRequired(user *models.User) error {
+	if user.PasswordResetRequired {
+		return ErrPasswordResetRequired
+	}
+	return nil
+}
+
+// Expired reports whether the user's local password must be replaced, because an administrator
+// required it or it is older than PASSWORD_MAX_AGE
+func (p *PasswordPolicy) Expired(user *models.User) bool {
+	if user.PasswordResetRequired {
+		return true
+	}
+
+	maxAge := passwordMaxAge()
+	if maxAge == 0 || user.Password == "" {
+		return false
+	}
+
+	// Passwords set before the change date was tracked count from account creation
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	return time.Since(changedAt) > maxAge
+}
+
+// isReused compares the password with the current hash and the last PASSWORD_HISTORY_SIZE ones
+func (p *PasswordPolicy) isReused(user *models.User, password string) (bool, error) {
+	if user.Password != "" && utils.CheckPasswordHash(password, user.Password) {
+		return true, nil
+	}
+
+	recent, err := p.historyRepo.Recent(user.ID, passwordHistorySize())
+	if err != nil {
+		return false, err
+	}
+	for _, hash := range recent {
+		if utils.CheckPasswordHash(password, hash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// passwordHistorySize returns how many previous passwords cannot be reused
+func passwordHistorySize() int {
+	size := config.GetEnvInt("PASSWORD_HISTORY_SIZE", 5)
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
+// passwordMaxAge returns how long a password stays valid, or 0 if passwords never expire
+func passwordMaxAge() time.Duration {
+	days := config.GetEnvInt("PASSWORD_MAX_AGE", 0)
+	if days <= 0 {
+		return 0
+	}
+	return 24 * time.Hour * time.Duration(days)
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+	"JwtSecurityImplementation/utils"
+
+	"gorm.io/gorm"
+)
+
+// stubBreachChecker reports the passwords it holds as breached, or fails if err is set
+type stubBreachChecker struct {
+	passwords map[string]bool
+	err       error
+}
+
+func (c stubBreachChecker) Breached(password string) (bool, error) {
+	return c.passwords[password], c.err
+}
+
+func TestPasswordPolicyBreachedPasswords(t *testing.T) {
+	user := &models.User{FirstName: "Jane", LastName: "Doe", Email: "jane@example.com"}
+
+	tests := []struct {
+		name     string
+		checker  stubBreachChecker
+		password string
+		want     bool
+	}{
+		{"hit", stubBreachChecker{passwords: map[string]bool{"P@ssw0rd-2024": true}}, "P@ssw0rd-2024", true},
+		{"miss", stubBreachChecker{passwords: map[string]bool{"P@ssw0rd-2024": true}}, "Gl4ss-Harbor-Quill", false},
+		{"unreadable corpus fails open", stubBreachChecker{err: errors.New("corpus missing")}, "P@ssw0rd-2024", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewPasswordPolicy(nil, tt.checker)
+
+			err := policy.Check(user, tt.password)
+			var policyErr *PasswordPolicyError
+			rejected := errors.As(err, &policyErr)
+			if rejected != tt.want {
+				t.Fatalf("Check(%q) = %v, want rejected %v", tt.password, err, tt.want)
+			}
+			if rejected && (len(policyErr.Violations) != 1 || errors.Is(err, ErrPasswordReused)) {
+				t.Errorf("Check(%q) violations = %v, want only the breach", tt.password, policyErr.Violations)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyRejectsRecentPasswords(t *testing.T) {
+	useCheapPasswordHashes(t)
+	t.Setenv("PASSWORD_HISTORY_SIZE", "2")
+
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	historyRepo := repositories.NewPasswordHistoryRepository(db)
+	policy := NewPasswordPolicy(historyRepo, nil)
+
+	hash := func(password string) string {
+		hashed, err := utils.HashPassword(password)
+		if err != nil {
+			t.Fatalf("HashPassword: %v", err)
+		}
+		return hashed
+	}
+
+	created := createTestUser(t, userRepo, &models.User{FirstName: "Jane", Email: "jane@example.com", Password: hash("Current-Pass1!")})
+	// CreateUser clears the hash from what it returns
+	user, err := userRepo.GetUserByID(created.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	// Oldest first, only the last PASSWORD_HISTORY_SIZE are remembered
+	for _, previous := range []string{"Oldest-Pass1!", "Older-Pass1!", "Recent-Pass1!"} {
+		if err := historyRepo.Add(user.ID, hash(previous)); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tests := []struct {
+		password string
+		reused   bool
+	}{
+		{"Current-Pass1!", true},
+		{"Recent-Pass1!", true},
+		{"Older-Pass1!", true},
+		{"Oldest-Pass1!", false},
+		{"Brand-New-Pass1!", false},
+	}
+	for _, tt := range tests {
+		err := policy.Check(user, tt.password)
+		if errors.Is(err, ErrPasswordReused) != tt.reused {
+			t.Errorf("Check(%q) = %v, want reused %v", tt.password, err, tt.reused)
+		}
+		if !tt.reused && err != nil {
+			t.Errorf("Check(%q) = %v, want no violation", tt.password, err)
+		}
+	}
+
+	// Users that are not stored yet have no history
+	if err := policy.Check(&models.User{FirstName: "New", Email: "new@example.com"}, "Recent-Pass1!"); err != nil {
+		t.Errorf("Check for a new user = %v, want no violation", err)
+	}
+}
+
+func TestPasswordPolicyExpired(t *testing.T) {
+	policy := NewPasswordPolicy(nil, nil)
+	longAgo := time.Now().Add(-100 * 24 * time.Hour)
+	recently := time.Now().Add(-24 * time.Hour)
+
+	tests := []struct {
+		name   string
+		maxAge string
+		user   models.User
+		want   bool
+	}{
+		{"reset required", "", models.User{Password: "hash", PasswordResetRequired: true, PasswordChangedAt: &recently}, true},
+		{"reset required without local password", "", models.User{PasswordResetRequired: true}, true},
+		{"no maximum age", "", models.User{Password: "hash", PasswordChangedAt: &longAgo}, false},
+		{"changed recently", "90", models.User{Password: "hash", PasswordChangedAt: &recently}, false},
+		{"changed too long ago", "90", models.User{Password: "hash", PasswordChangedAt: &longAgo}, true},
+		{"never changed, counts from creation", "90", models.User{Model: gorm.Model{CreatedAt: longAgo}, Password: "hash"}, true},
+		{"never changed, created recently", "90", models.User{Model: gorm.Model{CreatedAt: recently}, Password: "hash"}, false},
+		{"no local password", "90", models.User{Model: gorm.Model{CreatedAt: longAgo}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PASSWORD_MAX_AGE", tt.maxAge)
+			if got := policy.Expired(&tt.user); got != tt.want {
+				t.Errorf("Expired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
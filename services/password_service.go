@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/mailer"
+	"JwtSecurityImplementation/repositories"
+	"JwtSecurityImplementation/utils"
+)
+
+const purposePasswordReset = "password_reset"
+
+type PasswordService struct {
+	userRepo      *repositories.UserRepository
+	historyRepo   *repositories.PasswordHistoryRepository
+	policy        *PasswordPolicy
+	tokenService  *TokenService
+	oneTimeTokens *OneTimeTokenService
+	mailer        mailer.Mailer
+}
+
+func NewPasswordService(userRepo *repositories.UserRepository, historyRepo *repositories.PasswordHistoryRepository, policy *PasswordPolicy, tokenService *TokenService, oneTimeTokens *OneTimeTokenService, mailer mailer.Mailer) *PasswordService {
+	return &PasswordService{
+		userRepo:      userRepo,
+		historyRepo:   historyRepo,
+		policy:        policy,
+		tokenService:  tokenService,
+		oneTimeTokens: oneTimeTokens,
+		mailer:        mailer,
+	}
+}
+
+// ForgotPassword emails a short-lived, single-use reset link if the account exists.
+// Unknown addresses are ignored without error so callers cannot discover accounts.
+func (s *PasswordService) ForgotPassword(email string) error {
+	user, err := s.userRepo.FindUserByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	ttl := time.Minute * time.Duration(config.GetEnvInt("PASSWORD_RESET_TTL", 15))
+	token, err := s.oneTimeTokens.Issue(user.ID, purposePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+
+	resetURL := config.GetEnv("PASSWORD_RESET_URL", config.GetEnv("APP_BASE_URL", "http://localhost:8080")+"/reset-password")
+	link := fmt.Sprintf("%s?token=%s", resetURL, url.QueryEscape(token))
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nA password reset was requested for your account. Open the link below to choose a new password:\n\n%s\n\n"+
+			"The link expires in %s and can only be used once. If you did not request this, you can ignore this email.\n", user.FirstName, link, ttl),
+	})
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere.
+// A reset may follow a compromise, so personal access tokens are revoked as well.
+func (s *PasswordService) ResetPassword(token, newPassword string) error {
+	// Check the password against the whole policy, including the user's history and personal
+	// information, before the token is used up, so a rejected password does not burn the link
+	userID, err := s.oneTimeTokens.Peek(token, purposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return errors.New("token is invalid or has expired")
+	}
+
+	if err := s.policy.Check(user, newPassword); err != nil {
+		return err
+	}
+
+	record, err := s.oneTimeTokens.Consume(token, purposePasswordReset)
+	if err != nil {
+		return err
+	}
+	if record.UserID != user.ID {
+		return errors.New("token is invalid or has expired")
+	}
+
+	if err := s.setPassword(user, newPassword); err != nil {
+		return err
+	}
+
+	if err := s.tokenService.RevokeUserSessions(user.ID, ""); err != nil {
+		return err
+	}
+	return s.tokenService.RevokeAllPersonalAccessTokens(user.ID)
+}
+
+// ChangePassword replaces the password of a signed-in user after checking the current one.
+// Every other session is signed out; currentSessionID stays alive.
+func (s *PasswordService) ChangePassword(userID uint, currentSessionID, currentPassword, newPassword string) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if !utils.CheckPasswordHash(currentPassword, user.Password) {
+		return errors.New("current password is incorrect")
+	}
+
+	// Reports every broken rule, including reuse of the current or a recent password
+	if err := s.policy.Check(user, newPassword); err != nil {
+		return err
+	}
+
+	if err := s.setPassword(user, newPassword); err != nil {
+		return err
+	}
+
+	return s.tokenService.RevokeUserSessions(user.ID, currentSessionID)
+}
+
+// setPassword hashes and stores a new password, moving the old hash into the history
+func (s *PasswordService) setPassword(user *models.User, newPassword string) error {
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	if user.Password == "" {
+		return nil
+	}
+	if err := s.historyRepo.Add(user.ID, user.Password); err != nil {
+		return err
+	}
+	return s.historyRepo.Prune(user.ID, passwordHistorySize())
+}
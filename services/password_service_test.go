@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/pkg/mailer"
+	"JwtSecurityImplementation/repositories"
+	"JwtSecurityImplementation/utils"
+)
+
+func TestResetPasswordKeepsTheTokenWhenThePolicyRejectsThePassword(t *testing.T) {
+	useCheapPasswordHashes(t)
+
+	db := newTestDB(t)
+	userRepo := repositories.NewUserRepository(db)
+	historyRepo := repositories.NewPasswordHistoryRepository(db)
+	oneTimeTokens := NewOneTimeTokenService(repositories.NewOneTimeTokenRepository(db))
+	service := NewPasswordService(
+		userRepo,
+		historyRepo,
+		NewPasswordPolicy(historyRepo, nil),
+		NewTokenService(repositories.NewMemoryTokenStore(), userRepo, repositories.NewPersonalAccessTokenRepository(db)),
+		oneTimeTokens,
+		mailer.NewLogMailer("test@example.com"),
+	)
+
+	hashed, err := utils.HashPassword("Old-Passw0rd!")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	now := time.Now()
+	user := createTestUser(t, userRepo, &models.User{FirstName: "Zebulon", Email: "zebulon@example.com", Password: hashed, EmailVerifiedAt: &now})
+
+	token, err := oneTimeTokens.Issue(user.ID, purposePasswordReset, time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	// Rules that depend on the user are checked before the link is used up
+	for _, rejected := range []string{"Zebulon-Passw0rd!", "Old-Passw0rd!"} {
+		if err := service.ResetPassword(token, rejected); err == nil {
+			t.Fatalf("ResetPassword accepted %q", rejected)
+		}
+	}
+
+	if err := service.ResetPassword(token, "Fresh-Passw0rd!"); err != nil {
+		t.Fatalf("ResetPassword with an acceptable password after rejections: %v", err)
+	}
+	if err := service.ResetPassword(token, "Other-Passw0rd!"); err == nil {
+		t.Error("ResetPassword accepted a used token")
+	}
+
+	stored, err := userRepo.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if !utils.CheckPasswordHash("Fresh-Passw0rd!", stored.Password) {
+		t.Error("the new password was not stored")
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// TokenInventory lists every credential a user currently holds
+type TokenInventory struct {
+	Sessions             []models.Session             `json:"sessions"`
+	PersonalAccessTokens []models.PersonalAccessToken `json:"personalAccessTokens"`
+}
+
+// CreatePersonalAccessToken issues a scoped personal access token for a user.
+// The returned token string is never stored and cannot be retrieved again.
+func (ts *TokenService) CreatePersonalAccessToken(userID uint, name string, scopes []string, expiresInDays int) (*models.PersonalAccessToken, string, error) {
+	maxDays := config.GetEnvInt("PAT_MAX_EXPIRY_DAYS", 365)
+	if expiresInDays < 1 || expiresInDays > maxDays {
+		return nil, "", fmt.Errorf("expiry must be between 1 and %d days", maxDays)
+	}
+
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	pat := &models.PersonalAccessToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      strings.TrimSpace(name),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().AddDate(0, 0, expiresInDays),
+	}
+	if err := ts.patRepo.Create(pat); err != nil {
+		return nil, "", err
+	}
+
+	claims := jwt.MapClaims{
+		"user_id":    userID,
+		"jti":        pat.ID,
+		"scopes":     pat.Scopes,
+		"exp":        pat.ExpiresAt.Unix(),
+		"token_type": "pat",
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(getJWTSecret()))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pat, tokenString, nil
+}
+
+// ListPersonalAccessTokens returns the metadata of a user's personal access tokens
+func (ts *TokenService) ListPersonalAccessTokens(userID uint) ([]models.PersonalAccessToken, error) {
+	return ts.patRepo.ListByUser(userID)
+}
+
+// RevokePersonalAccessToken revokes one of the user's personal access tokens
+func (ts *TokenService) RevokePersonalAccessToken(userID uint, tokenID string) error {
+	return ts.patRepo.Revoke(userID, tokenID)
+}
+
+// RevokeAllPersonalAccessTokens revokes every personal access token of a user, e.g. after a password reset
+func (ts *TokenService) RevokeAllPersonalAccessTokens(userID uint) error {
+	return ts.patRepo.RevokeAllForUser(userID)
+}
+
+// GetTokenInventory returns the user's active sessions and personal access tokens
+func (ts *TokenService) GetTokenInventory(userID uint) (*TokenInventory, error) {
+	sessions, err := ts.tokenStore.ListActiveSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pats, err := ts.patRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenInventory{
+		Sessions:             sessions,
+		PersonalAccessTokens: pats,
+	}, nil
+}
+
+// validatePersonalAccessToken checks that the token behind a "pat" JWT is still active
+func (ts *TokenService) validatePersonalAccessToken(claims jwt.MapClaims) error {
+	tokenID, ok := claims["jti"].(string)
+	if !ok {
+		return errors.New("invalid personal access token")
+	}
+
+	pat, err := ts.patRepo.FindByID(tokenID)
+	if err != nil || !pat.IsActive() {
+		return errors.New("personal access token has been revoked or has expired")
+	}
+
+	// Personal access tokens outlive sessions, so they are checked against the owner on every use
+	user, err := ts.userRepo.GetUserByID(pat.UserID)
+	if err != nil {
+		return err
+	}
+	if user.IsSuspended() {
+		return ErrAccountSuspended
+	}
+
+	// Recording every request would write to the database on each API call
+	if pat.LastUsedAt != nil && time.Since(*pat.LastUsedAt) < time.Minute {
+		return nil
+	}
+	return ts.patRepo.TouchLastUsed(tokenID)
+}
+
+// normalizeScopes de-duplicates the requested scopes and checks them against PAT_SCOPES
+func normalizeScopes(scopes []string) ([]string, error) {
+	allowed := map[string]bool{}
+	for _, scope := range strings.Split(config.GetEnv("PAT_SCOPES", "read,write"), ",") {
+		allowed[strings.TrimSpace(scope)] = true
+	}
+
+	seen := map[string]bool{}
+	var normalized []string
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !allowed[scope] {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+
+	if len(normalized) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	return normalized, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/repositories"
+)
+
+const janitorLeaseName = "revocation-janitor"
+
+// RevocationJanitor periodically removes expired blacklist entries and sessions in the background.
+// When replicas share a database, only the replica holding the janitor lease does the work.
+type RevocationJanitor struct {
+	tokenStore     repositories.TokenStore
+	leaseRepo      *repositories.LeaseRepository
+	interval       time.Duration
+	batchSize      int
+	leaderElection bool
+	instanceID     string
+
+	stop         chan struct{}
+	done         chan struct{}
+	started      atomic.Bool
+	startOnce    sync.Once
+	stopOnce     sync.Once
+	totalRemoved atomic.Int64
+}
+
+// NewRevocationJanitor creates a janitor configured from the environment.
+// Without a lease repository every replica cleans its own store, e.g. for in-memory stores.
+func NewRevocationJanitor(tokenStore repositories.TokenStore, leaseRepo *repositories.LeaseRepository) *RevocationJanitor {
+	interval := config.GetEnvInt("JANITOR_INTERVAL_SECONDS", 300)
+	if interval < 1 {
+		interval = 300
+	}
+	batchSize := config.GetEnvInt("JANITOR_BATCH_SIZE", 500)
+	if batchSize < 1 {
+		batchSize = 500
+	}
+
+	return &RevocationJanitor{
+		tokenStore:     tokenStore,
+		leaseRepo:      leaseRepo,
+		interval:       time.Second * time.Duration(interval),
+		batchSize:      batchSize,
+		leaderElection: leaseRepo != nil && config.GetEnvBool("JANITOR_LEADER_ELECTION", true),
+		instanceID:     config.GetEnv("INSTANCE_ID", defaultInstanceID()),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+}
+
+// Start runs the janitor in a background goroutine until Stop is called
+func (j *RevocationJanitor) Start() {
+	j.startOnce.Do(func() {
+		j.started.Store(true)
+		go j.run()
+	})
+}
+
+// Stop signals the janitor to finish its current batch and waits for it to exit or ctx to expire.
+// A janitor that was never started stops at once.
+func (j *RevocationJanitor) Stop(ctx context.Context) error {
+	j.stopOnce.Do(func() {
+		close(j.stop)
+	})
+	if !j.started.Load() {
+		return nil
+	}
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TotalRemoved returns the number of rows removed since the janitor was created
+func (j *RevocationJanitor) TotalRemoved() int64 {
+	return j.totalRemoved.Load()
+}
+
+// RunOnce removes expired entries in batches and returns how many rows were deleted
+func (j *RevocationJanitor) RunOnce() (int64, error) {
+	tokens, err := j.deleteInBatches(j.tokenStore.DeleteExpiredTokens)
+	if err != nil {
+		return tokens, fmt.Errorf("failed to remove expired blacklisted tokens: %w", err)
+	}
+
+	sessions, err := j.deleteInBatches(j.tokenStore.DeleteExpiredSessions)
+	if err != nil {
+		return tokens + sessions, fmt.Errorf("failed to remove expired sessions: %w", err)
+	}
+
+	removed := tokens + sessions
+	j.totalRemoved.Add(removed)
+	if removed > 0 {
+		log.Printf("Revocation janitor removed %d expired blacklisted tokens and %d expired sessions", tokens, sessions)
+	}
+
+	return removed, nil
+}
+
+func (j *RevocationJanitor) run() {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.stop:
+			j.releaseLease()
+			return
+		case <-ticker.C:
+			if !j.isLeader() {
+				continue
+			}
+			if _, err := j.RunOnce(); err != nil {
+				log.Printf("Revocation janitor: %v", err)
+			}
+		}
+	}
+}
+
+// deleteInBatches keeps deleting until a batch comes back short or the janitor is stopped
+func (j *RevocationJanitor) deleteInBatches(deleteBatch func(batchSize int) (int64, error)) (int64, error) {
+	var total int64
+	for {
+		removed, err := deleteBatch(j.batchSize)
+		total += removed
+		if err != nil || removed < int64(j.batchSize) {
+			return total, err
+		}
+
+		select {
+		case <-j.stop:
+			return total, nil
+		default:
+		}
+	}
+}
+
+// isLeader reports whether this replica should run cleanup, renewing its lease if so
+func (j *RevocationJanitor) isLeader() bool {
+	if !j.leaderElection {
+		return true
+	}
+
+	// Hold the lease for two intervals so a missed tick does not hand it over
+	acquired, err := j.leaseRepo.TryAcquire(janitorLeaseName, j.instanceID, j.interval*2)
+	if err != nil {
+		log.Printf("Revocation janitor: failed to acquire lease: %v", err)
+		return false
+	}
+	return acquired
+}
+
+func (j *RevocationJanitor) releaseLease() {
+	if !j.leaderElection {
+		return
+	}
+	if err := j.leaseRepo.Release(janitorLeaseName, j.instanceID); err != nil {
+		log.Printf("Revocation janitor: failed to release lease: %v", err)
+	}
+}
+
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"JwtSecurityImplementation/internal/config"
+	"JwtSecurityImplementation/repositories"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var errInvalidStepUp = errors.New("step-up verification is missing or has expired")
+
+// IssueStepUpToken returns a short-lived token proving the user re-verified themselves
+// in the given session, e.g. with an emailed code, before a sensitive action
+func (ts *TokenService) IssueStepUpToken(userID uint, sessionID string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(time.Minute * time.Duration(config.GetEnvInt("STEP_UP_TTL", 5)))
+
+	claims := jwt.MapClaims{
+		"user_id":    userID,
+		"sid":        sessionID,
+		"jti":        uuid.NewString(),
+		"exp":        expiresAt.Unix(),
+		"token_type": "step_up",
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(getJWTSecret()))
+	return signed, expiresAt, err
+}
+
+// ValidateStepUpToken checks that a step-up token was issued for this user and session, and burns it
+// so every sensitive action needs its own verification
+func (ts *TokenService) ValidateStepUpToken(tokenString string, userID uint, sessionID string) error {
+	_, claims, err := ts.ValidateToken(tokenString)
+	if err != nil || claims["token_type"] != "step_up" {
+		return errInvalidStepUp
+	}
+
+	tokenUserID, ok := claims["user_id"].(float64)
+	if !ok || uint(tokenUserID) != userID || claims["sid"] != sessionID {
+		return errInvalidStepUp
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return errInvalidStepUp
+	}
+	if err := ts.ConsumeToken(tokenString, expiresAt.Time); err != nil {
+		if errors.Is(err, repositories.ErrTokenConsumed) {
+			return errInvalidStepUp
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"JwtSecurityImplementation/models"
+	"JwtSecurityImplementation/repositories"
+)
+
+// lockstepTokenStore holds every caller of IsTokenBlacklisted until all expected callers
+// have checked, so concurrent requests see the same state before any of them writes
+type lockstepTokenStore struct {
+	repositories.TokenStore
+	checked sync.WaitGroup
+}
+
+func (s *lockstepTokenStore) IsTokenBlacklisted(token string) bool {
+	blacklisted := s.TokenStore.IsTokenBlacklisted(token)
+	s.checked.Done()
+	s.checked.Wait()
+	return blacklisted
+}
+
+func TestStepUpTokenCanOnlyBeSpentOnce(t *testing.T) {
+	store := repositories.NewMemoryTokenStore()
+	if err := store.CreateSession(&models.Session{ID: "session", UserID: 7, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	token, _, err := NewTokenService(store, nil, nil).IssueStepUpToken(7, "session")
+	if err != nil {
+		t.Fatalf("IssueStepUpToken: %v", err)
+	}
+
+	const attempts = 4
+	lockstep := &lockstepTokenStore{TokenStore: store}
+	lockstep.checked.Add(attempts)
+	tokenService := NewTokenService(lockstep, nil, nil)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tokenService.ValidateStepUpToken(token, 7, "session") == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != 1 {
+		t.Errorf("step-up token accepted %d times by concurrent requests, want once", accepted)
+	}
+}
+
+func TestStepUpTokenIsBoundToUserAndSession(t *testing.T) {
+	store := repositories.NewMemoryTokenStore()
+	tokenService := NewTokenService(store, nil, nil)
+	if err := store.CreateSession(&models.Session{ID: "session", UserID: 7, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	token, _, err := tokenService.IssueStepUpToken(7, "session")
+	if err != nil {
+		t.Fatalf("IssueStepUpToken: %v", err)
+	}
+
+	if err := tokenService.ValidateStepUpToken(token, 8, "session"); err == nil {
+		t.Error("step-up token accepted for another user")
+	}
+	if err := tokenService.ValidateStepUpToken(token, 7, "other-session"); err == nil {
+		t.Error("step-up token accepted for another session")
+	}
+	if err := tokenService.ValidateStepUpToken(token, 7, "session"); err != nil {
+		t.Fatalf("ValidateStepUpToken: %v", err)
+	}
+	if err := tokenService.ValidateStepUpToken(token, 7, "session"); err == nil {
+		t.Error("step-up token accepted twice")
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"strconv"
 	"time"
@@ -10,46 +11,69 @@ import (
 	"JwtSecurityImplementation/repositories"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// RoleAdmin grants access to the administration endpoints
+const RoleAdmin = "admin"
+
 type TokenService struct {
-	tokenRepo *repositories.TokenRepository
-	userRepo  *repositories.UserRepository
+	tokenStore      repositories.TokenStore
+	userRepo        *repositories.UserRepository
+	patRepo         *repositories.PersonalAccessTokenRepository
+	claimsProviders []ClaimsProvider
 }
 
-func NewTokenService(tokenRepo *repositories.TokenRepository) *TokenService {
+func NewTokenService(tokenStore repositories.TokenStore, userRepo *repositories.UserRepository, patRepo *repositories.PersonalAccessTokenRepository) *TokenService {
 	return &TokenService{
-		tokenRepo: tokenRepo,
-		userRepo:  userRepo,
+		tokenStore: tokenStore,
+		userRepo:   userRepo,
+		patRepo:    patRepo,
 	}
 }
 
-func (ts *TokenService) GenerateTokenPair(user *models.User) (*models.TokenDetails, error) {
+// GenerateTokenPair starts a new session for the user and issues its access and refresh tokens.
+// Remember-me sessions use the long-lived refresh policy, all others the standard one.
+func (ts *TokenService) GenerateTokenPair(user *models.User, rememberMe bool, client models.ClientInfo) (*models.TokenDetails, error) {
 	// Get token expiration from environment or use defaults
 	accessTokenExpiry := getTokenExpiry("ACCESS_TOKEN_EXPIRY", 5)
-	refreshTokenExpiry := getTokenExpiry("REFRESH_TOKEN_EXPIRY", 60)
+	refreshTokenExpiry := getRefreshTokenExpiry(rememberMe)
 
-	// Access Token
-	accessTokenClaims := jwt.MapClaims{
-		"user_id":    user.ID,
-		"first_name": user.FirstName,
-		"last_name":  user.LastName,
-		"email":      user.Email,
-		"exp":        time.Now().Add(time.Minute * time.Duration(accessTokenExpiry)).Unix(),
-		"token_type": "access",
+	now := time.Now()
+	accessExpiresAt := now.Add(time.Minute * time.Duration(accessTokenExpiry))
+	refreshExpiresAt := now.Add(time.Minute * time.Duration(refreshTokenExpiry))
+
+	// Record the session so the refresh policy applied to it is known later
+	session := &models.Session{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		RememberMe: rememberMe,
+		UserAgent:  truncate(client.UserAgent, 255),
+		IPAddress:  truncate(client.IPAddress, 45),
+		ExpiresAt:  refreshExpiresAt,
+		LastUsedAt: now,
+	}
+	if err := ts.tokenStore.CreateSession(session); err != nil {
+		return nil, err
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaims)
-	accessTokenString, err := accessToken.SignedString([]byte(getJWTSecret()))
+	// Access Token
+	accessTokenString, err := ts.signAccessToken(user, session.ID, accessExpiresAt)
 	if err != nil {
+		// A failing claims provider aborts the login, the session must not linger without tokens
+		if revokeErr := ts.tokenStore.RevokeSession(session.ID); revokeErr != nil {
+			log.Printf("Failed to revoke session %s after token generation failed: %v", session.ID, revokeErr)
+		}
 		return nil, err
 	}
 
 	// Refresh Token
 	refreshTokenClaims := jwt.MapClaims{
-		"user_id":    user.ID,
-		"exp":        time.Now().Add(time.Minute * time.Duration(refreshTokenExpiry)).Unix(),
-		"token_type": "refresh",
+		"user_id":     user.ID,
+		"sid":         session.ID,
+		"remember_me": rememberMe,
+		"exp":         refreshExpiresAt.Unix(),
+		"token_type":  "refresh",
 	}
 
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshTokenClaims)
@@ -61,8 +85,10 @@ func (ts *TokenService) GenerateTokenPair(user *models.User) (*models.TokenDetai
 	return &models.TokenDetails{
 		AccessToken:        accessTokenString,
 		RefreshToken:       refreshTokenString,
-		AccessTokenExpiry:  time.Now().Add(time.Minute * time.Duration(accessTokenExpiry)),
-		RefreshTokenExpiry: time.Now().Add(time.Minute * time.Duration(refreshTokenExpiry)),
+		AccessTokenExpiry:  accessExpiresAt,
+		RefreshTokenExpiry: refreshExpiresAt,
+		SessionID:          session.ID,
+		RememberMe:         rememberMe,
 	}, nil
 }
 
@@ -77,7 +103,7 @@ func (ts *TokenService) ValidateToken(tokenString string) (*jwt.Token, jwt.MapCl
 	}
 
 	// Check if token is blacklisted
-	if ts.tokenRepo.IsTokenBlacklisted(tokenString) {
+	if ts.tokenStore.IsTokenBlacklisted(tokenString) {
 		return nil, nil, fmt.Errorf("token is blacklisted")
 	}
 
@@ -85,66 +111,125 @@ func (ts *TokenService) ValidateToken(tokenString string) (*jwt.Token, jwt.MapCl
 		return nil, nil, fmt.Errorf("invalid token")
 	}
 
+	// Tokens bound to a session die with it
+	if sessionID, ok := claims["sid"].(string); ok {
+		if !ts.tokenStore.IsSessionActive(sessionID) {
+			return nil, nil, fmt.Errorf("session has been revoked")
+		}
+	}
+
+	// Personal access tokens can be revoked individually
+	if claims["token_type"] == "pat" {
+		if err := ts.validatePersonalAccessToken(claims); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	return token, claims, nil
 }
 
 func (ts *TokenService) ValidateRefreshToken(refreshToken string) (*models.User, error) {
+	user, _, err := ts.validateRefreshToken(refreshToken)
+	return user, err
+}
+
+func (ts *TokenService) validateRefreshToken(refreshToken string) (*models.User, jwt.MapClaims, error) {
 	// Validate token
 	_, claims, err := ts.ValidateToken(refreshToken)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Ensure it's a refresh token
 	tokenType, ok := claims["token_type"].(string)
 	if !ok || tokenType != "refresh" {
-		return nil, fmt.Errorf("invalid refresh token")
+		return nil, nil, fmt.Errorf("invalid refresh token")
 	}
 
 	// Extract user ID
 	userID, ok := claims["user_id"].(float64)
 	if !ok {
-		return nil, fmt.Errorf("invalid user ID in token")
+		return nil, nil, fmt.Errorf("invalid user ID in token")
 	}
 
 	// Fetch user from the database
 	user, err := ts.userRepo.GetUserByID(uint(userID))
 	if err != nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, nil, fmt.Errorf("user not found")
+	}
+	// Suspension revokes the sessions, this also stops refresh tokens issued concurrently
+	if user.IsSuspended() {
+		return nil, nil, ErrAccountSuspended
 	}
 
-	return user, nil
+	return user, claims, nil
 }
 
 func (ts *TokenService) BlacklistToken(token string, expiresAt time.Time) error {
-	return ts.tokenRepo.BlacklistToken(token, expiresAt)
+	return ts.tokenStore.BlacklistToken(token, expiresAt)
+}
+
+// ConsumeToken burns a single-use token, failing with repositories.ErrTokenConsumed
+// if it has already been used, even by a concurrent request
+func (ts *TokenService) ConsumeToken(token string, expiresAt time.Time) error {
+	return ts.tokenStore.ConsumeToken(token, expiresAt)
+}
+
+// RevokeSession ends a session so neither of its tokens can be used again
+func (ts *TokenService) RevokeSession(sessionID string) error {
+	return ts.tokenStore.RevokeSession(sessionID)
+}
+
+// RevokeUserSessions ends every session of a user except exceptSessionID, which may be empty
+func (ts *TokenService) RevokeUserSessions(userID uint, exceptSessionID string) error {
+	return ts.tokenStore.RevokeUserSessions(userID, exceptSessionID)
 }
 
 func (ts *TokenService) RefreshAccessToken(refreshToken string) (string, error) {
 	// Validate refresh token and get user
-	user, err := ts.ValidateRefreshToken(refreshToken)
+	user, claims, err := ts.validateRefreshToken(refreshToken)
 	if err != nil {
 		return "", err
 	}
 
+	// Keep the new access token bound to the refresh token's session
+	sessionID, _ := claims["sid"].(string)
+	if sessionID != "" {
+		if err := ts.tokenStore.TouchSession(sessionID); err != nil {
+			return "", err
+		}
+	}
+
 	// Generate new access token
 	accessTokenExpiry := getTokenExpiry("ACCESS_TOKEN_EXPIRY", 5)
+	return ts.signAccessToken(user, sessionID, time.Now().Add(time.Minute*time.Duration(accessTokenExpiry)))
+}
+
+// signAccessToken mints an access token, enriched by the registered claims providers
+func (ts *TokenService) signAccessToken(user *models.User, sessionID string, expiresAt time.Time) (string, error) {
 	accessTokenClaims := jwt.MapClaims{
-		"user_id":    user.ID,
-		"first_name": user.FirstName,
-		"last_name":  user.LastName,
-		"email":      user.Email,
-		"exp":        time.Now().Add(time.Minute * time.Duration(accessTokenExpiry)).Unix(),
-		"token_type": "access",
+		"user_id":        user.ID,
+		"first_name":     user.FirstName,
+		"last_name":      user.LastName,
+		"email":          user.Email,
+		"email_verified": user.EmailVerifiedAt != nil,
+		"roles":          user.Roles,
+		"exp":            expiresAt.Unix(),
+		"token_type":     "access",
+	}
+	if sessionID != "" {
+		accessTokenClaims["sid"] = sessionID
+	}
+	if user.Tenant != "" {
+		accessTokenClaims["tenant"] = user.Tenant
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaims)
-	accessTokenString, err := accessToken.SignedString([]byte(getJWTSecret()))
-	if err != nil {
+	if err := ts.applyClaimsProviders(user, accessTokenClaims); err != nil {
 		return "", err
 	}
 
-	return accessTokenString, nil
+	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaims)
+	return accessToken.SignedString([]byte(getJWTSecret()))
 }
 
 // Helper functions
@@ -157,6 +242,21 @@ func getTokenExpiry(envKey string, defaultMinutes int) int {
 	return expiry
 }
 
+// getRefreshTokenExpiry returns the refresh lifetime in minutes for the given session policy
+func getRefreshTokenExpiry(rememberMe bool) int {
+	if rememberMe {
+		return getTokenExpiry("REMEMBER_ME_REFRESH_TOKEN_EXPIRY", 60*24*30)
+	}
+	return getTokenExpiry("REFRESH_TOKEN_EXPIRY", 60)
+}
+
+func truncate(value string, maxLength int) string {
+	if len(value) > maxLength {
+		return value[:maxLength]
+	}
+	return value
+}
+
 func getJWTSecret() string {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readVersionedSecrets loads secrets kept outside the database, either from a file with one
+// "<version>=<secret>" per line or, when no file is given, from <prefix><version> variables
+func readVersionedSecrets(path, prefix string, add func(version int, secret string) error) error {
+	if path == "" {
+		for _, variable := range os.Environ() {
+			name, secret, _ := strings.Cut(variable, "=")
+			version, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+			if !strings.HasPrefix(name, prefix) || err != nil || secret == "" {
+				continue
+			}
+			if err := add(version, secret); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		versionText, secret, found := strings.Cut(text, "=")
+		version, err := strconv.Atoi(strings.TrimSpace(versionText))
+		if !found || err != nil {
+			return fmt.Errorf("%s:%d: expected <version>=<secret>", path, line)
+		}
+		if err := add(version, strings.TrimSpace(secret)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
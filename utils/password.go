@@ -1,23 +1,114 @@
 package utils
 
 import (
+	"log"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 
-	"golang.org/x/crypto/bcrypt"
+	"JwtSecurityImplementation/internal/config"
 )
 
+// HashPassword hashes a password with the configured algorithm, Argon2id unless
+// PASSWORD_HASH_ALGORITHM says otherwise, after applying the current pepper if one is set
 func HashPassword(password string) (string, error) {
-	// Get bcrypt cost from environment or use default
-	cost := getBcryptCost()
+	version := currentPepperVersion()
+	peppered, err := pepperPassword(password, version)
+	if err != nil {
+		return "", err
+	}
 
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
-	return string(bytes), err
+	hash, err := defaultPasswordHasher().Hash(peppered)
+	if err != nil || version == 0 {
+		return hash, err
+	}
+	return pepperPrefix + strconv.Itoa(version) + hash, nil
 }
 
+// CheckPasswordHash verifies a password against a hash of any supported algorithm and pepper version
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	version, inner := splitPepperedHash(hash)
+	hasher := passwordHasherFor(inner)
+	if version < 0 || hasher == nil {
+		return false
+	}
+
+	peppered, err := pepperPassword(password, version)
+	if err != nil {
+		log.Printf("Failed to verify password: %v", err)
+		return false
+	}
+
+	ok, err := hasher.Verify(peppered, inner)
+	return err == nil && ok
+}
+
+// PasswordNeedsRehash reports whether a hash was made with another algorithm, other
+// parameters or another pepper than HashPassword uses now
+func PasswordNeedsRehash(hash string) bool {
+	version, inner := splitPepperedHash(hash)
+	hasher := defaultPasswordHasher()
+	return version != currentPepperVersion() || !hasher.Recognizes(inner) || hasher.NeedsRehash(inner)
+}
+
+// dummyPasswordHash is checked when there is no stored hash, so unknown accounts cost as much as
+// known ones. It is remade whenever the hashing configuration changes.
+var dummyPasswordHash = struct {
+	sync.Mutex
+	hash string
+}{}
+
+// CheckDummyPasswordHash spends the time of a password check without revealing that no hash exists
+func CheckDummyPasswordHash(password string) {
+	dummyPasswordHash.Lock()
+	if dummyPasswordHash.hash == "" || PasswordNeedsRehash(dummyPasswordHash.hash) {
+		hash, err := HashPassword("dummy password for unknown accounts")
+		if err != nil {
+			dummyPasswordHash.Unlock()
+			return
+		}
+		dummyPasswordHash.hash = hash
+	}
+	hash := dummyPasswordHash.hash
+	dummyPasswordHash.Unlock()
+
+	CheckPasswordHash(password, hash)
+}
+
+// defaultPasswordHasher returns the hasher new passwords are stored with
+func defaultPasswordHasher() PasswordHasher {
+	if strings.EqualFold(config.GetEnv("PASSWORD_HASH_ALGORITHM", "argon2id"), "bcrypt") {
+		return BcryptHasher{Cost: getBcryptCost()}
+	}
+
+	// Defaults follow the second recommended option of RFC 9106
+	return Argon2idHasher{
+		Memory:      uint32(envInRange("ARGON2_MEMORY", 64*1024, 8*1024, 4*1024*1024)),
+		Iterations:  uint32(envInRange("ARGON2_ITERATIONS", 3, 1, 64)),
+		Parallelism: uint8(envInRange("ARGON2_PARALLELISM", 4, 1, 255)),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// envInRange returns an integer environment variable, or the fallback if it is unset or out of range
+func envInRange(key string, fallback, min, max int) int {
+	value := config.GetEnvInt(key, fallback)
+	if value < min || value > max {
+		return fallback
+	}
+	return value
+}
+
+// passwordHasherFor returns the hasher that can verify an encoded hash, or nil
+func passwordHasherFor(hash string) PasswordHasher {
+	for _, hasher := range []PasswordHasher{defaultPasswordHasher(), Argon2idHasher{}, BcryptHasher{}} {
+		if hasher.Recognizes(hash) {
+			return hasher
+		}
+	}
+	return nil
 }
 
 // Helper function to get bcrypt cost from environment
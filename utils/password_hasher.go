@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errInvalidPasswordHash = errors.New("invalid password hash")
+
+// PasswordHasher produces and verifies encoded password hashes of one algorithm.
+// The encoding names the algorithm and its parameters, so hashes stay verifiable
+// after the configured parameters change.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// Recognizes reports whether an encoded hash was produced by this algorithm
+	Recognizes(encoded string) bool
+	// NeedsRehash reports whether a recognized hash uses other parameters than the hasher
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2idHasher encodes hashes in the PHC string format,
+// $argon2id$v=19$m=<KiB>,t=<iterations>,p=<parallelism>$<salt>$<hash>
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type argon2idHash struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	decoded, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), decoded.salt, decoded.iterations, decoded.memory, decoded.parallelism, uint32(len(decoded.key)))
+	return subtle.ConstantTimeCompare(key, decoded.key) == 1, nil
+}
+
+func (h Argon2idHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	decoded, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return decoded.version != argon2.Version || decoded.memory != h.Memory || decoded.iterations != h.Iterations ||
+		decoded.parallelism != h.Parallelism || uint32(len(decoded.salt)) != h.SaltLength || uint32(len(decoded.key)) != h.KeyLength
+}
+
+func decodeArgon2id(encoded string) (*argon2idHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errInvalidPasswordHash
+	}
+
+	decoded := &argon2idHash{}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &decoded.version); err != nil {
+		return nil, errInvalidPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &decoded.memory, &decoded.iterations, &decoded.parallelism); err != nil {
+		return nil, errInvalidPasswordHash
+	}
+	// argon2.IDKey panics on zero iterations or parallelism, and Argon2 needs 8 KiB per lane
+	if decoded.iterations < 1 || decoded.parallelism < 1 || decoded.memory < 8*uint32(decoded.parallelism) {
+		return nil, errInvalidPasswordHash
+	}
+
+	var err error
+	if decoded.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil || len(decoded.salt) == 0 {
+		return nil, errInvalidPasswordHash
+	}
+	if decoded.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(decoded.key) == 0 {
+		return nil, errInvalidPasswordHash
+	}
+	return decoded, nil
+}
+
+// BcryptHasher produces $2a$ hashes. bcrypt only looks at the first 72 bytes of a password,
+// so it is kept mainly to verify hashes stored before Argon2id became the default.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(hash), err
+}
+
+func (h BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h BcryptHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
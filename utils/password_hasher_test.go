@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+// testArgon2idHasher keeps the cost of hashing in tests low
+var testArgon2idHasher = Argon2idHasher{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idRejectsMalformedHashes(t *testing.T) {
+	const salt = "c29tZXNhbHRzb21lc2FsdA"
+	const key = "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"wrong algorithm", "$argon2i$v=19$m=8192,t=1,p=1$" + salt + "$" + key},
+		{"missing field", "$argon2id$v=19$m=8192,t=1,p=1$" + salt},
+		{"bad version", "$argon2id$v=x$m=8192,t=1,p=1$" + salt + "$" + key},
+		{"bad parameters", "$argon2id$v=19$m=8192;t=1;p=1$" + salt + "$" + key},
+		{"zero iterations", "$argon2id$v=19$m=8192,t=0,p=1$" + salt + "$" + key},
+		{"zero parallelism", "$argon2id$v=19$m=8192,t=1,p=0$" + salt + "$" + key},
+		{"parallelism out of range", "$argon2id$v=19$m=8192,t=1,p=256$" + salt + "$" + key},
+		{"memory below 8 KiB per lane", "$argon2id$v=19$m=15,t=1,p=2$" + salt + "$" + key},
+		{"empty salt", "$argon2id$v=19$m=8192,t=1,p=1$$" + key},
+		{"salt not base64", "$argon2id$v=19$m=8192,t=1,p=1$not*base64$" + key},
+		{"empty key", "$argon2id$v=19$m=8192,t=1,p=1$" + salt + "$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := testArgon2idHasher.Verify("password", tt.encoded)
+			if ok || !errors.Is(err, errInvalidPasswordHash) {
+				t.Errorf("Verify(%q) = %v, %v, want false, errInvalidPasswordHash", tt.encoded, ok, err)
+			}
+			if !testArgon2idHasher.NeedsRehash(tt.encoded) {
+				t.Errorf("NeedsRehash(%q) = false for a malformed hash", tt.encoded)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"JwtSecurityImplementation/internal/config"
+)
+
+// PasswordRules are the composition rules a new password must follow, configured through PASSWORD_* variables
+type PasswordRules struct {
+	MinLength int
+	// MaxLength is measured in bytes and caps the work of hashing a password
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	// RejectPersonalInfo refuses passwords containing the user's name or email address
+	RejectPersonalInfo bool
+}
+
+// LoadPasswordRules reads the password rules from the environment
+func LoadPasswordRules() PasswordRules {
+	return PasswordRules{
+		MinLength:          config.GetEnvInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:          config.GetEnvInt("PASSWORD_MAX_LENGTH", 128),
+		RequireUpper:       config.GetEnvBool("PASSWORD_REQUIRE_UPPERCASE", true),
+		RequireLower:       config.GetEnvBool("PASSWORD_REQUIRE_LOWERCASE", true),
+		RequireDigit:       config.GetEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSpecial:     config.GetEnvBool("PASSWORD_REQUIRE_SPECIAL", true),
+		RejectPersonalInfo: config.GetEnvBool("PASSWORD_REJECT_PERSONAL_INFO", true),
+	}
+}
+
+// Violations returns every rule the password breaks. personal holds the user's names and
+// email address, which the password must not contain.
+func (r PasswordRules) Violations(password string, personal ...string) []string {
+	var violations []string
+
+	if r.MinLength > 0 && utf8.RuneCountInString(password) < r.MinLength {
+		violations = append(violations, fmt.Sprintf("password must be at least %d characters long", r.MinLength))
+	}
+	if r.MaxLength > 0 && len(password) > r.MaxLength {
+		violations = append(violations, fmt.Sprintf("password must be at most %d bytes long", r.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			hasUpper = true
+		case unicode.IsLower(char):
+			hasLower = true
+		case unicode.IsDigit(char):
+			hasDigit = true
+		case !unicode.IsLetter(char) && !unicode.IsSpace(char):
+			hasSpecial = true
+		}
+	}
+	if r.RequireUpper && !hasUpper {
+		violations = append(violations, "password must contain an uppercase letter")
+	}
+	if r.RequireLower && !hasLower {
+		violations = append(violations, "password must contain a lowercase letter")
+	}
+	if r.RequireDigit && !hasDigit {
+		violations = append(violations, "password must contain a number")
+	}
+	if r.RequireSpecial && !hasSpecial {
+		violations = append(violations, "password must contain a special character")
+	}
+
+	if r.RejectPersonalInfo && containsPersonalInfo(password, personal) {
+		violations = append(violations, "password must not contain your name or email address")
+	}
+
+	return violations
+}
+
+// containsPersonalInfo reports whether the password contains one of the values, or the local part
+// of an email address among them. Values shorter than three characters are ignored.
+func containsPersonalInfo(password string, personal []string) bool {
+	password = strings.ToLower(password)
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		candidates := []string{value}
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			candidates = append(candidates, local)
+		}
+
+		for _, candidate := range candidates {
+			if utf8.RuneCountInString(candidate) >= 3 && strings.Contains(password, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// useCheapArgon2id makes HashPassword produce Argon2id hashes that are fast to compute
+func useCheapArgon2id(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	t.Setenv("ARGON2_MEMORY", "8192")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	encoded, err := testArgon2idHasher.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Fatalf("Hash = %q, want the PHC string of the parameters", encoded)
+	}
+
+	decoded, err := decodeArgon2id(encoded)
+	if err != nil {
+		t.Fatalf("decodeArgon2id: %v", err)
+	}
+	if decoded.memory != 8192 || decoded.iterations != 1 || decoded.parallelism != 1 || len(decoded.salt) != 16 || len(decoded.key) != 32 {
+		t.Errorf("decodeArgon2id = %+v, want the parameters of the hasher", decoded)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"correct horse battery staple", true},
+		{"correct horse battery stapl", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		ok, err := testArgon2idHasher.Verify(tt.password, encoded)
+		if err != nil || ok != tt.want {
+			t.Errorf("Verify(%q) = %v, %v, want %v", tt.password, ok, err, tt.want)
+		}
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	encoded, err := testArgon2idHasher.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		hasher Argon2idHasher
+		want   bool
+	}{
+		{"same parameters", testArgon2idHasher, false},
+		{"more memory", Argon2idHasher{Memory: 16 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}, true},
+		{"more iterations", Argon2idHasher{Memory: 8 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}, true},
+		{"more lanes", Argon2idHasher{Memory: 8 * 1024, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32}, true},
+		{"longer key", Argon2idHasher{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 64}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(encoded); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBcryptHashIsRehashedToArgon2id(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "bcrypt")
+	t.Setenv("BCRYPT_COST", "10")
+	legacy, err := HashPassword("password")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(legacy, "$2a$10$") {
+		t.Fatalf("HashPassword with bcrypt = %q, want a $2a$ hash", legacy)
+	}
+	if PasswordNeedsRehash(legacy) {
+		t.Error("bcrypt hash needs a rehash while bcrypt is configured")
+	}
+
+	useCheapArgon2id(t)
+	if !CheckPasswordHash("password", legacy) {
+		t.Fatal("bcrypt hash no longer verifies after switching to Argon2id")
+	}
+	if CheckPasswordHash("wrong", legacy) {
+		t.Fatal("bcrypt hash accepts a wrong password")
+	}
+	if !PasswordNeedsRehash(legacy) {
+		t.Fatal("bcrypt hash does not need a rehash once Argon2id is configured")
+	}
+
+	rehashed, err := HashPassword("password")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(rehashed, "$argon2id$") || PasswordNeedsRehash(rehashed) {
+		t.Errorf("rehashed password = %q, want a current Argon2id hash", rehashed)
+	}
+	if !CheckPasswordHash("password", rehashed) {
+		t.Error("rehashed password does not verify")
+	}
+}
+
+func TestCheckPasswordHashRejectsUnknownFormats(t *testing.T) {
+	useCheapArgon2id(t)
+
+	for _, hash := range []string{"", "password", "$md5$abc", "$pepper$k=x$argon2id$v=19$", "$pepper$k=1"} {
+		if CheckPasswordHash("password", hash) {
+			t.Errorf("CheckPasswordHash accepted %q", hash)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"JwtSecurityImplementation/internal/config"
+)
+
+// pepperPrefix marks a peppered hash; the version of the pepper and the inner hash follow,
+// e.g. $pepper$k=2$argon2id$v=19$...
+const pepperPrefix = "$pepper$k="
+
+// minPepperLength keeps peppers long enough to resist guessing alongside a stolen database
+const minPepperLength = 16
+
+// passwordPeppers holds the secrets mixed into passwords before hashing, kept out of the database
+var passwordPeppers = struct {
+	sync.RWMutex
+	current int
+	keys    map[int][]byte
+}{}
+
+// LoadPasswordPeppers reads the peppers from PASSWORD_PEPPER_FILE, one "<version>=<secret>" per line,
+// or from PASSWORD_PEPPER_<version> variables. New hashes use PASSWORD_PEPPER_VERSION, 0 for none.
+// Older versions must stay configured until every user still on them has logged in.
+func LoadPasswordPeppers() error {
+	keys := map[int][]byte{}
+
+	err := readVersionedSecrets(config.GetEnv("PASSWORD_PEPPER_FILE", ""), "PASSWORD_PEPPER_", func(version int, secret string) error {
+		return addPepper(keys, version, secret)
+	})
+	if err != nil {
+		return err
+	}
+
+	current := config.GetEnvInt("PASSWORD_PEPPER_VERSION", 0)
+	if _, ok := keys[current]; current != 0 && !ok {
+		return fmt.Errorf("password pepper version %d is not configured", current)
+	}
+
+	passwordPeppers.Lock()
+	defer passwordPeppers.Unlock()
+	passwordPeppers.current = current
+	passwordPeppers.keys = keys
+	return nil
+}
+
+func addPepper(keys map[int][]byte, version int, secret string) error {
+	if version < 1 {
+		return fmt.Errorf("password pepper version %d must be positive", version)
+	}
+	if len(secret) < minPepperLength {
+		return fmt.Errorf("password pepper version %d must be at least %d characters", version, minPepperLength)
+	}
+	keys[version] = []byte(secret)
+	return nil
+}
+
+// currentPepperVersion returns the pepper version new hashes are made with, 0 for none
+func currentPepperVersion() int {
+	passwordPeppers.RLock()
+	defer passwordPeppers.RUnlock()
+	return passwordPeppers.current
+}
+
+// pepperPassword returns the HMAC of the password under the given pepper version,
+// or the password itself for version 0
+func pepperPassword(password string, version int) (string, error) {
+	if version == 0 {
+		return password, nil
+	}
+
+	passwordPeppers.RLock()
+	key, ok := passwordPeppers.keys[version]
+	passwordPeppers.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("password pepper version %d is not configured", version)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// splitPepperedHash returns the pepper version of an encoded hash and the hash of the peppered password
+func splitPepperedHash(hash string) (int, string) {
+	if !strings.HasPrefix(hash, pepperPrefix) {
+		return 0, hash
+	}
+
+	rest := hash[len(pepperPrefix):]
+	end := strings.IndexByte(rest, '$')
+	if end < 0 {
+		return -1, ""
+	}
+	version, err := strconv.Atoi(rest[:end])
+	if err != nil || version < 1 {
+		return -1, ""
+	}
+	return version, rest[end:]
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	testPepper1 = "first pepper, long enough"
+	testPepper2 = "second pepper, long enough"
+)
+
+// loadTestPeppers configures the given pepper versions, at most 3, for the rest of the test
+// and restores the process configuration afterwards
+func loadTestPeppers(t *testing.T, current int, peppers map[int]string) {
+	t.Helper()
+
+	// Registered before t.Setenv, so it runs after the variables have been restored
+	t.Cleanup(func() {
+		if err := LoadPasswordPeppers(); err != nil {
+			t.Errorf("restore peppers: %v", err)
+		}
+	})
+
+	// Empty variables are skipped, which retires versions an earlier call configured
+	for version := 1; version <= 3; version++ {
+		t.Setenv("PASSWORD_PEPPER_"+strconv.Itoa(version), peppers[version])
+	}
+	t.Setenv("PASSWORD_PEPPER_VERSION", strconv.Itoa(current))
+	if err := LoadPasswordPeppers(); err != nil {
+		t.Fatalf("LoadPasswordPeppers: %v", err)
+	}
+}
+
+func TestHashPasswordUsesCurrentPepperVersion(t *testing.T) {
+	useCheapArgon2id(t)
+
+	tests := []struct {
+		name    string
+		current int
+		prefix  string
+	}{
+		{"no pepper", 0, "$argon2id$"},
+		{"version 1", 1, "$pepper$k=1$argon2id$"},
+		{"version 2", 2, "$pepper$k=2$argon2id$"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadTestPeppers(t, tt.current, map[int]string{1: testPepper1, 2: testPepper2})
+
+			hash, err := HashPassword("password")
+			if err != nil {
+				t.Fatalf("HashPassword: %v", err)
+			}
+			if !strings.HasPrefix(hash, tt.prefix) {
+				t.Errorf("HashPassword = %q, want prefix %q", hash, tt.prefix)
+			}
+			if !CheckPasswordHash("password", hash) || CheckPasswordHash("wrong", hash) {
+				t.Error("peppered hash does not verify the password it was made from")
+			}
+			if PasswordNeedsRehash(hash) {
+				t.Error("fresh hash needs a rehash")
+			}
+		})
+	}
+}
+
+func TestPepperRotationMigratesLazily(t *testing.T) {
+	useCheapArgon2id(t)
+
+	loadTestPeppers(t, 0, nil)
+	unpeppered, err := HashPassword("password")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	loadTestPeppers(t, 1, map[int]string{1: testPepper1})
+	old, err := HashPassword("password")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	// Version 2 becomes current while version 1 stays configured for the users still on it
+	loadTestPeppers(t, 2, map[int]string{1: testPepper1, 2: testPepper2})
+	for name, hash := range map[string]string{"unpeppered": unpeppered, "version 1": old} {
+		if !CheckPasswordHash("password", hash) {
+			t.Errorf("%s hash no longer verifies after rotation", name)
+		}
+		if !PasswordNeedsRehash(hash) {
+			t.Errorf("%s hash does not need a rehash after rotation", name)
+		}
+	}
+
+	current, err := HashPassword("password")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(current, "$pepper$k=2$") || PasswordNeedsRehash(current) {
+		t.Fatalf("rehashed password = %q, want a current version 2 hash", current)
+	}
+
+	// Once version 1 is retired, hashes still on it can no longer be verified
+	loadTestPeppers(t, 2, map[int]string{2: testPepper2})
+	if CheckPasswordHash("password", old) {
+		t.Error("hash of a retired pepper version still verifies")
+	}
+	if !CheckPasswordHash("password", current) {
+		t.Error("current hash does not verify after retiring version 1")
+	}
+}
+
+func TestPepperIsNotPartOfTheHash(t *testing.T) {
+	useCheapArgon2id(t)
+
+	loadTestPeppers(t, 1, map[int]string{1: testPepper1})
+	hash, err := HashPassword("password")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	// The same version number with another secret, e.g. a stolen database without the pepper
+	loadTestPeppers(t, 1, map[int]string{1: "another pepper, long enough"})
+	if CheckPasswordHash("password", hash) {
+		t.Error("hash verifies under a different pepper")
+	}
+}
+
+func TestLoadPasswordPeppers(t *testing.T) {
+	t.Cleanup(func() {
+		if err := LoadPasswordPeppers(); err != nil {
+			t.Errorf("restore peppers: %v", err)
+		}
+	})
+
+	writeFile := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "peppers")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write pepper file: %v", err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		file    string
+		wantErr bool
+	}{
+		{"nothing configured", nil, "", false},
+		{"current version from environment", map[string]string{"PASSWORD_PEPPER_1": testPepper1, "PASSWORD_PEPPER_VERSION": "1"}, "", false},
+		{"current version missing", map[string]string{"PASSWORD_PEPPER_1": testPepper1, "PASSWORD_PEPPER_VERSION": "2"}, "", true},
+		{"pepper too short", map[string]string{"PASSWORD_PEPPER_1": "short"}, "", true},
+		{"version not positive", map[string]string{"PASSWORD_PEPPER_0": testPepper1}, "", true},
+		{"file with comments", map[string]string{"PASSWORD_PEPPER_VERSION": "2"}, "# rotated yearly\n1=" + testPepper1 + "\n\n2 = " + testPepper2 + "\n", false},
+		{"malformed file", nil, "1:" + testPepper1 + "\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if tt.file != "" {
+				t.Setenv("PASSWORD_PEPPER_FILE", writeFile(t, tt.file))
+			}
+
+			err := LoadPasswordPeppers()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadPasswordPeppers() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
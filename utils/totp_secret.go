@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"JwtSecurityImplementation/internal/config"
+)
+
+// totpSecretPrefix marks an encrypted TOTP secret; the key version and the sealed secret follow,
+// e.g. $totp$k=1$<base64 nonce and ciphertext>
+const totpSecretPrefix = "$totp$k="
+
+// minTOTPKeyLength keeps the encryption keys as long as the password peppers
+const minTOTPKeyLength = 16
+
+// totpKeys holds the AES-256 keys TOTP secrets are encrypted with, kept out of the database
+var totpKeys = struct {
+	sync.RWMutex
+	current int
+	keys    map[int]cipher.AEAD
+}{}
+
+// LoadTOTPEncryptionKeys reads the keys from TOTP_ENCRYPTION_KEY_FILE, one "<version>=<secret>" per line,
+// or from TOTP_ENCRYPTION_KEY_<version> variables. New secrets use TOTP_ENCRYPTION_KEY_VERSION, 0 for none.
+// Older versions must stay configured until every user still on them has verified a code.
+func LoadTOTPEncryptionKeys() error {
+	keys := map[int]cipher.AEAD{}
+
+	err := readVersionedSecrets(config.GetEnv("TOTP_ENCRYPTION_KEY_FILE", ""), "TOTP_ENCRYPTION_KEY_", func(version int, secret string) error {
+		if version < 1 {
+			return fmt.Errorf("TOTP encryption key version %d must be positive", version)
+		}
+		if len(secret) < minTOTPKeyLength {
+			return fmt.Errorf("TOTP encryption key version %d must be at least %d characters", version, minTOTPKeyLength)
+		}
+
+		key := sha256.Sum256([]byte(secret))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return err
+		}
+		keys[version], err = cipher.NewGCM(block)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	current := config.GetEnvInt("TOTP_ENCRYPTION_KEY_VERSION", 0)
+	if _, ok := keys[current]; current != 0 && !ok {
+		return fmt.Errorf("TOTP encryption key version %d is not configured", current)
+	}
+
+	totpKeys.Lock()
+	defer totpKeys.Unlock()
+	totpKeys.current = current
+	totpKeys.keys = keys
+	return nil
+}
+
+// SealTOTPSecret encrypts a TOTP secret under the current key, bound to the user it belongs to.
+// It returns the secret unchanged while no key version is configured.
+func SealTOTPSecret(secret string, userID uint) (string, error) {
+	totpKeys.RLock()
+	version := totpKeys.current
+	aead := totpKeys.keys[version]
+	totpKeys.RUnlock()
+	if version == 0 {
+		return secret, nil
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(secret), totpAdditionalData(userID))
+	return totpSecretPrefix + strconv.Itoa(version) + "$" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenTOTPSecret decrypts a stored TOTP secret. Secrets stored before encryption was enabled
+// are returned as they are.
+func OpenTOTPSecret(stored string, userID uint) (string, error) {
+	if !strings.HasPrefix(stored, totpSecretPrefix) {
+		return stored, nil
+	}
+
+	versionText, encoded, found := strings.Cut(stored[len(totpSecretPrefix):], "$")
+	version, err := strconv.Atoi(versionText)
+	if !found || err != nil {
+		return "", errors.New("malformed TOTP secret")
+	}
+
+	totpKeys.RLock()
+	aead, ok := totpKeys.keys[version]
+	totpKeys.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("TOTP encryption key version %d is not configured", version)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed TOTP secret")
+	}
+	secret, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], totpAdditionalData(userID))
+	if err != nil {
+		return "", errors.New("TOTP secret cannot be decrypted")
+	}
+	return string(secret), nil
+}
+
+// TOTPSecretNeedsRekey reports whether a stored secret is not sealed under the current key version
+func TOTPSecretNeedsRekey(stored string) bool {
+	totpKeys.RLock()
+	current := totpKeys.current
+	totpKeys.RUnlock()
+
+	if !strings.HasPrefix(stored, totpSecretPrefix) {
+		return current != 0
+	}
+	versionText, _, _ := strings.Cut(stored[len(totpSecretPrefix):], "$")
+	return versionText != strconv.Itoa(current)
+}
+
+// totpAdditionalData ties a sealed secret to its user so it cannot be copied to another account
+func totpAdditionalData(userID uint) []byte {
+	return []byte("totp:" + strconv.FormatUint(uint64(userID), 10))
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	testTOTPKey1 = "first TOTP key, long enough"
+	testTOTPKey2 = "second TOTP key, long enough"
+)
+
+// loadTestTOTPKeys configures the given key versions, at most 3, for the rest of the test
+// and restores the process configuration afterwards
+func loadTestTOTPKeys(t *testing.T, current int, keys map[int]string) {
+	t.Helper()
+
+	// Registered before t.Setenv, so it runs after the variables have been restored
+	t.Cleanup(func() {
+		if err := LoadTOTPEncryptionKeys(); err != nil {
+			t.Errorf("restore TOTP keys: %v", err)
+		}
+	})
+
+	for version := 1; version <= 3; version++ {
+		t.Setenv("TOTP_ENCRYPTION_KEY_"+strconv.Itoa(version), keys[version])
+	}
+	t.Setenv("TOTP_ENCRYPTION_KEY_VERSION", strconv.Itoa(current))
+	if err := LoadTOTPEncryptionKeys(); err != nil {
+		t.Fatalf("LoadTOTPEncryptionKeys: %v", err)
+	}
+}
+
+func TestSealTOTPSecretRoundTrip(t *testing.T) {
+	loadTestTOTPKeys(t, 1, map[int]string{1: testTOTPKey1})
+
+	sealed, err := SealTOTPSecret(rfc6238Secret, 42)
+	if err != nil {
+		t.Fatalf("SealTOTPSecret: %v", err)
+	}
+	if !strings.HasPrefix(sealed, "$totp$k=1$") || strings.Contains(sealed, rfc6238Secret) {
+		t.Fatalf("SealTOTPSecret = %q, want the secret encrypted under version 1", sealed)
+	}
+	if len(sealed) > 128 {
+		t.Errorf("sealed secret is %d characters, the column holds 128", len(sealed))
+	}
+
+	again, err := SealTOTPSecret(rfc6238Secret, 42)
+	if err != nil || again == sealed {
+		t.Errorf("sealing the same secret twice gave the same ciphertext, nonces must differ")
+	}
+
+	opened, err := OpenTOTPSecret(sealed, 42)
+	if err != nil || opened != rfc6238Secret {
+		t.Errorf("OpenTOTPSecret = %q, %v, want the original secret", opened, err)
+	}
+}
+
+func TestOpenTOTPSecretRejectsTampering(t *testing.T) {
+	loadTestTOTPKeys(t, 1, map[int]string{1: testTOTPKey1})
+
+	sealed, err := SealTOTPSecret(rfc6238Secret, 42)
+	if err != nil {
+		t.Fatalf("SealTOTPSecret: %v", err)
+	}
+	encoded := strings.TrimPrefix(sealed, "$totp$k=1$")
+	raw, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("decode sealed secret: %v", err)
+	}
+	raw[len(raw)-1] ^= 0x01
+	flipped := "$totp$k=1$" + base64.RawStdEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name   string
+		stored string
+		userID uint
+	}{
+		{"flipped ciphertext bit", flipped, 42},
+		{"copied to another user", sealed, 43},
+		{"truncated", "$totp$k=1$" + encoded[:8], 42},
+		{"not base64", "$totp$k=1$!!!", 42},
+		{"missing version", "$totp$k=" + encoded, 42},
+		{"unknown version", "$totp$k=9$" + encoded, 42},
+	}
+	for _, tt := range tests {
+		if opened, err := OpenTOTPSecret(tt.stored, tt.userID); err == nil {
+			t.Errorf("%s: OpenTOTPSecret = %q, want an error", tt.name, opened)
+		}
+	}
+}
+
+func TestTOTPKeyRotation(t *testing.T) {
+	loadTestTOTPKeys(t, 0, nil)
+	plain, err := SealTOTPSecret(rfc6238Secret, 42)
+	if err != nil || plain != rfc6238Secret {
+		t.Fatalf("SealTOTPSecret without a key = %q, %v, want the secret unchanged", plain, err)
+	}
+	if TOTPSecretNeedsRekey(plain) {
+		t.Error("plaintext secret needs a rekey while encryption is off")
+	}
+
+	loadTestTOTPKeys(t, 1, map[int]string{1: testTOTPKey1})
+	old, err := SealTOTPSecret(rfc6238Secret, 42)
+	if err != nil {
+		t.Fatalf("SealTOTPSecret: %v", err)
+	}
+	if !TOTPSecretNeedsRekey(plain) {
+		t.Error("plaintext secret does not need a rekey once encryption is on")
+	}
+
+	// Version 2 becomes current while version 1 stays configured for the users still on it
+	loadTestTOTPKeys(t, 2, map[int]string{1: testTOTPKey1, 2: testTOTPKey2})
+	for name, stored := range map[string]string{"plaintext": plain, "version 1": old} {
+		if opened, err := OpenTOTPSecret(stored, 42); err != nil || opened != rfc6238Secret {
+			t.Errorf("%s secret opens as %q, %v after rotation", name, opened, err)
+		}
+		if !TOTPSecretNeedsRekey(stored) {
+			t.Errorf("%s secret does not need a rekey after rotation", name)
+		}
+	}
+
+	current, err := SealTOTPSecret(rfc6238Secret, 42)
+	if err != nil {
+		t.Fatalf("SealTOTPSecret: %v", err)
+	}
+	if !strings.HasPrefix(current, "$totp$k=2$") || TOTPSecretNeedsRekey(current) {
+		t.Errorf("rekeyed secret = %q, want it sealed under version 2", current)
+	}
+
+	// Once version 1 is retired, secrets still on it cannot be opened
+	loadTestTOTPKeys(t, 2, map[int]string{2: testTOTPKey2})
+	if _, err := OpenTOTPSecret(old, 42); err == nil {
+		t.Error("secret of a retired key version still opens")
+	}
+}
+
+func TestLoadTOTPEncryptionKeys(t *testing.T) {
+	t.Cleanup(func() {
+		if err := LoadTOTPEncryptionKeys(); err != nil {
+			t.Errorf("restore TOTP keys: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{"nothing configured", nil, false},
+		{"current version configured", map[string]string{"TOTP_ENCRYPTION_KEY_1": testTOTPKey1, "TOTP_ENCRYPTION_KEY_VERSION": "1"}, false},
+		{"current version missing", map[string]string{"TOTP_ENCRYPTION_KEY_1": testTOTPKey1, "TOTP_ENCRYPTION_KEY_VERSION": "2"}, true},
+		{"key too short", map[string]string{"TOTP_ENCRYPTION_KEY_1": "short"}, true},
+		{"version not positive", map[string]string{"TOTP_ENCRYPTION_KEY_0": testTOTPKey1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if err := LoadTOTPEncryptionKeys(); (err != nil) != tt.wantErr {
+				t.Errorf("LoadTOTPEncryptionKeys() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 test vectors, "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTPMatchesRFC6238Vectors(t *testing.T) {
+	// The RFC lists eight digit codes, six digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		at := time.Unix(tt.unix, 0)
+		step, ok := ValidateTOTP(rfc6238Secret, tt.code, at, 0)
+		if !ok || step != tt.unix/30 {
+			t.Errorf("ValidateTOTP(%s) at %d = %d, %v, want step %d", tt.code, tt.unix, step, ok, tt.unix/30)
+		}
+		if got := hotp([]byte("12345678901234567890"), tt.unix/30); got != tt.code {
+			t.Errorf("hotp at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	// 1111111109 is in step 37037036, its code is 081804
+	at := time.Unix(1111111109, 0)
+	const code = "081804"
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		skew   int
+		want   bool
+	}{
+		{"same step", 0, 0, true},
+		{"one step later without skew", 30 * time.Second, 0, false},
+		{"one step later", 30 * time.Second, 1, true},
+		{"one step earlier", -30 * time.Second, 1, true},
+		{"two steps later", 60 * time.Second, 1, false},
+		{"two steps earlier", -60 * time.Second, 1, false},
+	}
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfc6238Secret, code, at.Add(tt.offset), tt.skew)
+		if ok != tt.want {
+			t.Errorf("%s: ValidateTOTP = %v, want %v", tt.name, ok, tt.want)
+		}
+		// The step identifies the code, not the time it was entered, so replays can be refused
+		if ok && step != 37037036 {
+			t.Errorf("%s: ValidateTOTP step = %d, want 37037036", tt.name, step)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		want   bool
+	}{
+		{"surrounding spaces", rfc6238Secret, " 287082 ", true},
+		{"lower case secret", strings.ToLower(rfc6238Secret), "287082", true},
+		{"too short", rfc6238Secret, "28708", false},
+		{"too long", rfc6238Secret, "2870820", false},
+		{"empty", rfc6238Secret, "", false},
+		{"secret not base32", "not-base32!", "287082", false},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, at, 0); ok != tt.want {
+			t.Errorf("%s: ValidateTOTP = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	key, err := base32NoPadding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("GenerateTOTPSecret = %q, want 160 bits of unpadded base32", secret)
+	}
+
+	other, err := GenerateTOTPSecret()
+	if err != nil || other == secret {
+		t.Errorf("GenerateTOTPSecret returned %q twice", secret)
+	}
+
+	uri, err := url.Parse(TOTPURI("Example", "jane@example.com", secret))
+	if err != nil {
+		t.Fatalf("parse TOTP URI: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Query().Get("secret") != secret || uri.Query().Get("issuer") != "Example" {
+		t.Errorf("TOTPURI = %s, want an otpauth://totp URI with secret and issuer", uri)
+	}
+}